@@ -1,105 +1,282 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
 
 	"gophermart/internal/config"
+	"gophermart/internal/diagnostics"
+	"gophermart/internal/grpcapi"
 	"gophermart/internal/handlers"
+	"gophermart/internal/metrics"
 	"gophermart/internal/middleware"
+	"gophermart/internal/models"
+	"gophermart/internal/openapi"
+	"gophermart/internal/ratelimit"
 	"gophermart/internal/repository"
+	"gophermart/internal/router"
 	"gophermart/internal/services"
+	"gophermart/internal/tracing"
+	"gophermart/internal/utils"
 )
 
 func main() {
-	cfg := config.NewConfig()
+	cfg, err := config.NewConfig()
+	if err != nil {
+		fatal("Failed to load config", "error", err)
+	}
+	if err := utils.SetupLogger(cfg.LogLevel, cfg.LogFormat); err != nil {
+		fatal("Failed to set up logger", "error", err)
+	}
+	slog.Info("Loaded config", "config", cfg)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TraceExporter)
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
 
 	// init repo
-	repo, err := repository.NewRepository(cfg.DatabaseURI)
+	repo, err := repository.NewRepository(cfg.DatabaseURI, int32(cfg.DBMaxConns), int32(cfg.DBMinConns))
 	if err != nil {
-		log.Fatalf("Failed to initialize repository: %v", err)
+		fatal("Failed to initialize repository", "error", err)
 	}
 	defer repo.Close()
 
+	// init password hasher
+	argon2Params, err := utils.NewArgon2Params(cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+	if err != nil {
+		fatal("Invalid password hashing parameters", "error", err)
+	}
+	hasher, err := utils.NewArgon2idHasher(argon2Params)
+	if err != nil {
+		fatal("Invalid password hashing parameters", "error", err)
+	}
+
+	// tokens signed before a secret rotation stay valid for the grace period
+	signingKey := utils.NewSigningKey(cfg.JWTSecret, cfg.JWTSecretGracePeriod)
+
 	// init services
-	userService := services.NewUserService(repo)
-	orderService := services.NewOrderService(repo, cfg.AccrualSystemAddress)
-	balanceService := services.NewBalanceService(repo)
+	userService := services.NewUserService(repo, hasher, cfg.AdminLogins)
+	twoFactorService := services.NewTwoFactorService(repo)
+	orderEventService := services.NewOrderEventService(repo)
+	orderService := services.NewOrderService(repo, orderEventService, cfg.AccrualSystemAddress, cfg.AccrualPollInterval, cfg.AccrualTimeout)
+	balanceService := services.NewBalanceService(repo, twoFactorService, float32(cfg.TwoFactorWithdrawalThreshold))
+	adminService := services.NewAdminService(repo)
+	apiKeyService := services.NewAPIKeyService(repo)
+	sessionService := services.NewSessionService(repo, cfg.TokenTTL)
+	accountService := services.NewAccountService(repo, hasher, twoFactorService)
+	healthService := services.NewHealthService(repo, orderService)
+	auditService := services.NewAuditService(repo)
 
 	// init handlers
-	userHandler := handlers.NewUserHandler(userService, cfg.JWTSecret)
+	userHandler := handlers.NewUserHandler(userService, twoFactorService, sessionService, signingKey)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
 	orderHandler := handlers.NewOrderHandler(orderService)
 	balanceHandler := handlers.NewBalanceHandler(balanceService)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	orderEventHandler := handlers.NewOrderEventHandler(orderEventService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	healthHandler := handlers.NewHealthHandler(healthService)
+	auditHandler := handlers.NewAuditHandler(auditService)
 
 	// init middleware
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWTSecret)
+	authMiddleware := middleware.NewAuthMiddleware(signingKey, apiKeyService, sessionService)
+	gzipMiddleware := middleware.NewGzipMiddleware(middleware.DefaultMaxDecompressedSize, middleware.DefaultGzipMinSize)
+
+	var rateLimitStore ratelimit.Store
+	switch cfg.RateLimitStore {
+	case "memory":
+		rateLimitStore = ratelimit.NewMemoryStore()
+	case "postgres":
+		rateLimitStore = repo
+	default:
+		fatal("Unknown rate limit store", "store", cfg.RateLimitStore)
+	}
+	rateLimiter := middleware.NewRateLimiter(rateLimitStore, rateLimitPolicies(cfg))
+	loginLimit := rateLimiter.Limit("login")
+	registerLimit := rateLimiter.Limit("register")
+	ordersLimit := rateLimiter.Limit("orders")
+
+	liveConfig := &runtimeConfig{
+		signingKey:   signingKey,
+		repo:         repo,
+		orderService: orderService,
+		rateLimiter:  rateLimiter,
+	}
+	liveConfig.current.Store(cfg)
 
 	// creates a router
-	mux := http.NewServeMux()
+	r := router.New()
+	r.Use(middleware.RequestID, middleware.ClientIP, middleware.Tracing, middleware.AccessLog, middleware.Metrics, gzipMiddleware.Decompress, gzipMiddleware.Compress)
+	if cfg.OpenAPIValidate {
+		doc, err := openapi.Load()
+		if err != nil {
+			fatal("Failed to load OpenAPI document", "error", err)
+		}
+		validator := openapi.NewValidator(doc, func(r *http.Request, err error) {
+			slog.ErrorContext(r.Context(), "OpenAPI violation", "method", r.Method, "path", r.URL.Path, "error", err)
+		})
+		r.Use(validator.Validate)
+	}
+
+	// orchestrator probes
+	r.Get("/healthz", healthHandler.Live)
+	r.Get("/readyz", healthHandler.Ready)
+
+	// Prometheus metrics
+	metrics.RegisterPool(repo.PoolStat)
+	r.Handle(http.MethodGet, "/metrics", metrics.Handler())
+
+	// API description
+	r.Get("/api/openapi.json", openapi.Handler)
 
 	// public routes
-	mux.HandleFunc("/api/user/register", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		userHandler.Register(w, r)
-	})
+	public := r.Group("/api/user")
+	public.With(registerLimit).Post("/register", userHandler.Register)
+	public.With(loginLimit).Post("/login", userHandler.Login)
+	public.With(loginLimit).Post("/login/2fa", userHandler.LoginTwoFactor)
 
-	mux.HandleFunc("/api/user/login", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		userHandler.Login(w, r)
-	})
-
-	// protected routes
-	mux.HandleFunc("/api/user/orders", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			authMiddleware.Auth(http.HandlerFunc(orderHandler.UploadOrder)).ServeHTTP(w, r)
-		case http.MethodGet:
-			authMiddleware.Auth(http.HandlerFunc(orderHandler.GetUserOrders)).ServeHTTP(w, r)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
+	// protected routes, some of them also accept partner API keys
+	api := r.Group("/api")
+	api.With(authMiddleware.AuthScoped(models.ScopeOrdersWrite), ordersLimit).Post("/user/orders", orderHandler.UploadOrder)
+	api.With(authMiddleware.AuthScoped(models.ScopeOrdersWrite), ordersLimit).Post("/user/orders/batch", orderHandler.UploadOrderBatch)
+	api.With(authMiddleware.AuthScoped(models.ScopeUsersRead), ordersLimit).Get("/user/orders", orderHandler.GetUserOrders)
+	api.With(authMiddleware.AuthScoped(models.ScopeUsersRead)).Get("/orders/{number}", orderHandler.GetOrder)
+	api.With(authMiddleware.AuthScoped(models.ScopeUsersRead)).Get("/user/balance", balanceHandler.GetBalance)
+	api.With(authMiddleware.AuthScoped(models.ScopeBalanceWithdraw)).Post("/user/balance/withdraw", balanceHandler.CreateWithdrawal)
+	api.With(authMiddleware.AuthScoped(models.ScopeUsersRead)).Get("/user/withdrawals", balanceHandler.GetWithdrawals)
 
-	mux.HandleFunc("/api/orders/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		authMiddleware.Auth(http.HandlerFunc(orderHandler.GetOrder)).ServeHTTP(w, r)
-	})
+	// routes available only to signed-in users
+	user := r.Group("/api/user", authMiddleware.Auth)
+	user.Post("/2fa/setup", twoFactorHandler.Setup)
+	user.Post("/2fa/confirm", twoFactorHandler.Confirm)
+	user.Post("/2fa/disable", twoFactorHandler.Disable)
+	user.Get("/orders/events", orderEventHandler.Stream)
+	user.Get("/sessions", sessionHandler.List)
+	user.Delete("/sessions/{id}", sessionHandler.Revoke)
+	user.Get("/export", accountHandler.Export)
+	r.With(authMiddleware.Auth).Delete("/api/user", accountHandler.Delete)
 
-	mux.HandleFunc("/api/user/balance", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+	// admin routes
+	admin := r.Group("/api/admin", authMiddleware.Auth, middleware.RequireRole(models.RoleAdmin))
+	admin.Get("/users/{login}", adminHandler.GetUser)
+	admin.Post("/users/{login}/balance", adminHandler.AdjustBalance)
+	admin.Put("/orders/{number}/status", adminHandler.OverrideOrderStatus)
+	admin.Post("/api-keys", apiKeyHandler.Create)
+	admin.Get("/api-keys", apiKeyHandler.List)
+	admin.Delete("/api-keys/{id}", apiKeyHandler.Revoke)
+	admin.Get("/audit", auditHandler.List)
+	admin.Get("/audit/verify", auditHandler.Verify)
+
+	// gRPC API served alongside HTTP
+	var grpcServer *grpc.Server
+	if cfg.GRPCAddress != "" {
+		grpcServer = grpcapi.NewServer(userService, twoFactorService, sessionService, orderService, orderEventService, balanceService, signingKey)
+		listener, err := net.Listen("tcp", cfg.GRPCAddress)
+		if err != nil {
+			fatal("Failed to listen", "address", cfg.GRPCAddress, "error", err)
 		}
-		authMiddleware.Auth(http.HandlerFunc(balanceHandler.GetBalance)).ServeHTTP(w, r)
-	})
 
-	mux.HandleFunc("/api/user/balance/withdraw", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+		go func() {
+			slog.Info("Starting gRPC server", "address", cfg.GRPCAddress)
+			if err := grpcServer.Serve(listener); err != nil {
+				fatal("Failed to start gRPC server", "error", err)
+			}
+		}()
+	}
+
+	// diagnostics on a separate listener, never on the public one
+	var adminServer *http.Server
+	if cfg.AdminAddress != "" {
+		adminServer = &http.Server{Addr: cfg.AdminAddress, Handler: diagnostics.NewHandler(liveConfig.Config, orderService)}
+		go func() {
+			slog.Info("Starting admin server", "address", cfg.AdminAddress)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("Failed to start admin server", "error", err)
+			}
+		}()
+	}
+
+	server := &http.Server{
+		Addr:              cfg.RunAddress,
+		Handler:           r,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+	go func() {
+		slog.Info("Starting server", "address", cfg.RunAddress)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("Failed to start server", "error", err)
 		}
-		authMiddleware.Auth(http.HandlerFunc(balanceHandler.CreateWithdrawal)).ServeHTTP(w, r)
-	})
+	}()
 
-	mux.HandleFunc("/api/user/withdrawals", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+	// apply the reloadable part of the config and rotated secret files on SIGHUP or when the config file changes
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	fileChanged := make(chan struct{}, 1)
+	if cfg.ConfigFile != "" && cfg.ConfigWatchInterval > 0 {
+		go config.WatchFile(cfg.ConfigFile, cfg.ConfigWatchInterval, fileChanged)
+	}
+	go func() {
+		for {
+			select {
+			case <-hangup:
+				liveConfig.Reload("signal")
+			case <-fileChanged:
+				liveConfig.Reload("file")
+			}
 		}
-		authMiddleware.Auth(http.HandlerFunc(balanceHandler.GetWithdrawals)).ServeHTTP(w, r)
-	})
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	// fail readiness first so that traffic is routed away before the listeners close
+	healthService.SetShuttingDown()
+	slog.Info("Shutting down", "drain_delay", cfg.ShutdownDrainDelay)
+	time.Sleep(cfg.ShutdownDrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	log.Printf("Starting server on %s", cfg.RunAddress)
-	if err := http.ListenAndServe(cfg.RunAddress, mux); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Failed to shut down server gracefully", "error", err)
+		server.Close()
+	}
+	if adminServer != nil {
+		adminServer.Shutdown(shutdownCtx)
 	}
+	slog.Info("Server stopped")
+}
+
+// logs an error and exits
+func fatal(msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"log/slog"
+	"sync/atomic"
+
+	"gophermart/internal/config"
+	"gophermart/internal/middleware"
+	"gophermart/internal/ratelimit"
+	"gophermart/internal/repository"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents the config of the running process and the components it is applied to on reload
+type runtimeConfig struct {
+	current      atomic.Pointer[config.Config]
+	signingKey   *utils.SigningKey
+	repo         *repository.Repository
+	orderService *services.OrderService
+	rateLimiter  *middleware.RateLimiter
+}
+
+// returns the config in effect
+func (rc *runtimeConfig) Config() *config.Config {
+	return rc.current.Load()
+}
+
+// reloads the config and applies it to the running components; a config that fails to load or
+// validate is rejected as a whole and the previous one is kept
+func (rc *runtimeConfig) Reload(trigger string) {
+	current := rc.current.Load()
+	next, ignored, err := current.Reload()
+	if err != nil {
+		slog.Error("Rejected config reload", "trigger", trigger, "error", err)
+		return
+	}
+
+	// the only step that can fail goes first so that nothing is applied when it does
+	if next.DatabaseURI != current.DatabaseURI {
+		if err := rc.repo.SetCredentials(next.DatabaseURI); err != nil {
+			slog.Error("Rejected config reload", "trigger", trigger, "error", err)
+			return
+		}
+		slog.Info("Reloaded database credentials")
+	}
+	if rc.signingKey.Rotate(next.JWTSecret) {
+		slog.Info("Rotated JWT secret")
+	}
+	if err := utils.SetLogLevel(next.LogLevel); err != nil {
+		slog.Error("Failed to change log level", "error", err)
+	}
+	rc.orderService.SetSweepInterval(next.AccrualPollInterval)
+	rc.rateLimiter.SetPolicies(rateLimitPolicies(next))
+	rc.current.Store(next)
+
+	if len(ignored) > 0 {
+		slog.Warn("Config changes need a restart to apply", "fields", ignored)
+	}
+	slog.Info("Reloaded config", "trigger", trigger, "config", next)
+}
+
+// returns the rate limit policies, per client IP on public routes and per user on protected ones
+func rateLimitPolicies(cfg *config.Config) []ratelimit.Policy {
+	return []ratelimit.Policy{
+		{Name: "login", Limit: cfg.LoginRateLimit.Limit, Window: cfg.LoginRateLimit.Window},
+		{Name: "register", Limit: cfg.RegisterRateLimit.Limit, Window: cfg.RegisterRateLimit.Window},
+		{Name: "orders", Limit: cfg.OrdersRateLimit.Limit, Window: cfg.OrdersRateLimit.Window},
+	}
+}
@@ -1,39 +1,482 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
 )
 
+// represents the service config, filled from defaults, the config file, environment variables and flags in turn
 type Config struct {
-	RunAddress           string
-	DatabaseURI          string
-	AccrualSystemAddress string
-	JWTSecret            string
+	// path of the YAML or TOML config file, empty to use none
+	ConfigFile string `yaml:"-" toml:"-"`
+	// how often the config file is checked for changes to reload, zero to reload on SIGHUP only
+	ConfigWatchInterval time.Duration `yaml:"config_watch_interval" toml:"config_watch_interval"`
+
+	RunAddress           string `yaml:"run_address" toml:"run_address"`
+	GRPCAddress          string `yaml:"grpc_address" toml:"grpc_address"`
+	DatabaseURI          string `yaml:"database_uri" toml:"database_uri"`
+	AccrualSystemAddress string `yaml:"accrual_system_address" toml:"accrual_system_address"`
+	JWTSecret            string `yaml:"jwt_secret" toml:"jwt_secret"`
+
+	// files holding the JWT secret and the database URI, for mounted secrets; re-read on SIGHUP
+	JWTSecretFile   string `yaml:"jwt_secret_file" toml:"jwt_secret_file"`
+	DatabaseURIFile string `yaml:"database_uri_file" toml:"database_uri_file"`
+
+	// lifetime of access tokens and sessions
+	TokenTTL time.Duration `yaml:"token_ttl" toml:"token_ttl"`
+	// how long tokens signed with the previous JWT secret are accepted after a rotation, zero to reject them at once
+	JWTSecretGracePeriod time.Duration `yaml:"jwt_secret_grace_period" toml:"jwt_secret_grace_period"`
+
+	// how often orders still in processing are rechecked, and the timeout of a single accrual request
+	AccrualPollInterval time.Duration `yaml:"accrual_poll_interval" toml:"accrual_poll_interval"`
+	AccrualTimeout      time.Duration `yaml:"accrual_timeout" toml:"accrual_timeout"`
+
+	// HTTP server timeouts, zero disables a timeout; the write timeout stays zero for SSE streams
+	HTTPReadHeaderTimeout time.Duration `yaml:"http_read_header_timeout" toml:"http_read_header_timeout"`
+	HTTPReadTimeout       time.Duration `yaml:"http_read_timeout" toml:"http_read_timeout"`
+	HTTPWriteTimeout      time.Duration `yaml:"http_write_timeout" toml:"http_write_timeout"`
+	HTTPIdleTimeout       time.Duration `yaml:"http_idle_timeout" toml:"http_idle_timeout"`
+
+	// time between failing readiness and closing the listeners, and time given to in-flight requests
+	ShutdownDrainDelay time.Duration `yaml:"shutdown_drain_delay" toml:"shutdown_drain_delay"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+
+	// database connection pool bounds
+	DBMaxConns int `yaml:"db_max_conns" toml:"db_max_conns"`
+	DBMinConns int `yaml:"db_min_conns" toml:"db_min_conns"`
+
+	// argon2id password hashing parameters
+	Argon2Memory      uint `yaml:"argon2_memory" toml:"argon2_memory"`
+	Argon2Iterations  uint `yaml:"argon2_iterations" toml:"argon2_iterations"`
+	Argon2Parallelism uint `yaml:"argon2_parallelism" toml:"argon2_parallelism"`
+
+	// withdrawals above this sum require a TOTP code from users with 2FA enabled
+	TwoFactorWithdrawalThreshold float64 `yaml:"two_factor_withdrawal_threshold" toml:"two_factor_withdrawal_threshold"`
+
+	// logins granted the admin role on registration
+	AdminLogins []string `yaml:"admin_logins" toml:"admin_logins"`
+
+	// rate limit bucket storage: memory or postgres
+	RateLimitStore string `yaml:"rate_limit_store" toml:"rate_limit_store"`
+
+	// rate limits per client IP on public routes and per user on protected ones
+	LoginRateLimit    RateLimit `yaml:"login_rate_limit" toml:"login_rate_limit"`
+	RegisterRateLimit RateLimit `yaml:"register_rate_limit" toml:"register_rate_limit"`
+	OrdersRateLimit   RateLimit `yaml:"orders_rate_limit" toml:"orders_rate_limit"`
+
+	// checks traffic against the OpenAPI document, meant for tests
+	OpenAPIValidate bool `yaml:"openapi_validate" toml:"openapi_validate"`
+
+	// log level: debug, info, warn or error; log format: text or json
+	LogLevel  string `yaml:"log_level" toml:"log_level"`
+	LogFormat string `yaml:"log_format" toml:"log_format"`
+
+	// span exporter: none, stdout or otlp
+	TraceExporter string `yaml:"trace_exporter" toml:"trace_exporter"`
+
+	// address of the diagnostics listener, empty to disable it
+	AdminAddress string `yaml:"admin_address" toml:"admin_address"`
+}
+
+const (
+	// replaces secret values
+	redacted = "REDACTED"
+
+	// shortest accepted JWT secret, in bytes
+	minJWTSecretLength = 32
+)
+
+// maps environment variables to the flags they set
+var envVars = []struct {
+	name string
+	flag string
+	// an empty value is applied instead of ignored
+	allowEmpty bool
+}{
+	{name: "CONFIG_WATCH_INTERVAL", flag: "config-watch-interval"},
+	{name: "RUN_ADDRESS", flag: "a"},
+	{name: "GRPC_ADDRESS", flag: "g", allowEmpty: true},
+	{name: "DATABASE_URI", flag: "d"},
+	{name: "ACCRUAL_SYSTEM_ADDRESS", flag: "r"},
+	{name: "JWT_SECRET", flag: "j"},
+	{name: "DATABASE_URI_FILE", flag: "database-uri-file"},
+	{name: "JWT_SECRET_FILE", flag: "jwt-secret-file"},
+	{name: "TOKEN_TTL", flag: "token-ttl"},
+	{name: "JWT_SECRET_GRACE_PERIOD", flag: "jwt-secret-grace-period"},
+	{name: "ACCRUAL_POLL_INTERVAL", flag: "accrual-poll-interval"},
+	{name: "ACCRUAL_TIMEOUT", flag: "accrual-timeout"},
+	{name: "HTTP_READ_HEADER_TIMEOUT", flag: "http-read-header-timeout"},
+	{name: "HTTP_READ_TIMEOUT", flag: "http-read-timeout"},
+	{name: "HTTP_WRITE_TIMEOUT", flag: "http-write-timeout"},
+	{name: "HTTP_IDLE_TIMEOUT", flag: "http-idle-timeout"},
+	{name: "SHUTDOWN_DRAIN_DELAY", flag: "shutdown-drain-delay"},
+	{name: "SHUTDOWN_TIMEOUT", flag: "shutdown-timeout"},
+	{name: "DB_MAX_CONNS", flag: "db-max-conns"},
+	{name: "DB_MIN_CONNS", flag: "db-min-conns"},
+	{name: "ARGON2_MEMORY", flag: "argon2-memory"},
+	{name: "ARGON2_ITERATIONS", flag: "argon2-iterations"},
+	{name: "ARGON2_PARALLELISM", flag: "argon2-parallelism"},
+	{name: "TWO_FACTOR_WITHDRAWAL_THRESHOLD", flag: "2fa-withdrawal-threshold"},
+	{name: "ADMIN_LOGINS", flag: "admin-logins"},
+	{name: "RATE_LIMIT_STORE", flag: "rate-limit-store"},
+	{name: "LOGIN_RATE_LIMIT", flag: "login-rate-limit"},
+	{name: "REGISTER_RATE_LIMIT", flag: "register-rate-limit"},
+	{name: "ORDERS_RATE_LIMIT", flag: "orders-rate-limit"},
+	{name: "OPENAPI_VALIDATE", flag: "openapi-validate"},
+	{name: "LOG_LEVEL", flag: "log-level"},
+	{name: "LOG_FORMAT", flag: "log-format"},
+	{name: "TRACE_EXPORTER", flag: "trace-exporter"},
+	{name: "ADMIN_ADDRESS", flag: "admin-address"},
+}
+
+// pairs the flags setting a secret directly with the flags reading it from a file
+var secretFlags = map[string]string{
+	"d":                 "database-uri-file",
+	"database-uri-file": "d",
+	"j":                 "jwt-secret-file",
+	"jwt-secret-file":   "j",
+}
+
+// returns the config used when no source sets a value
+func defaultConfig() Config {
+	return Config{
+		ConfigWatchInterval:          5 * time.Second,
+		RunAddress:                   ":8080",
+		TokenTTL:                     24 * time.Hour,
+		JWTSecretGracePeriod:         5 * time.Minute,
+		AccrualPollInterval:          10 * time.Second,
+		AccrualTimeout:               5 * time.Second,
+		HTTPReadHeaderTimeout:        5 * time.Second,
+		HTTPReadTimeout:              30 * time.Second,
+		HTTPIdleTimeout:              2 * time.Minute,
+		ShutdownDrainDelay:           5 * time.Second,
+		ShutdownTimeout:              15 * time.Second,
+		DBMaxConns:                   10,
+		Argon2Memory:                 64 * 1024,
+		Argon2Iterations:             3,
+		Argon2Parallelism:            2,
+		TwoFactorWithdrawalThreshold: 500,
+		RateLimitStore:               "memory",
+		LoginRateLimit:               RateLimit{Limit: 10, Window: time.Minute},
+		RegisterRateLimit:            RateLimit{Limit: 5, Window: time.Minute},
+		OrdersRateLimit:              RateLimit{Limit: 60, Window: time.Minute},
+		LogLevel:                     "info",
+		LogFormat:                    "text",
+		TraceExporter:                "none",
+	}
+}
+
+// loads the config from the command line, the environment and the config file, and validates it
+func NewConfig() (*Config, error) {
+	return load(os.Args[1:])
+}
+
+// loads and validates the config for the given command line arguments
+func load(args []string) (*Config, error) {
+	cfg := defaultConfig()
+	fs := cfg.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// flags are applied last, remember the ones given
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	path := cfg.ConfigFile
+	if _, ok := flags["config"]; !ok {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	// start over from the defaults and apply each source in order of precedence
+	cfg = defaultConfig()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	cfg.ConfigFile = path
+
+	env := make(map[string]string)
+	envNames := make(map[string]string)
+	for _, v := range envVars {
+		value, ok := os.LookupEnv(v.name)
+		if !ok || (value == "" && !v.allowEmpty) {
+			continue
+		}
+		env[v.flag] = value
+		envNames[v.flag] = v.name
+	}
+	if err := applySource(fs, env, func(flag string) string { return envNames[flag] }); err != nil {
+		return nil, err
+	}
+	if err := applySource(fs, flags, func(flag string) string { return "-" + flag }); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.readSecretFiles(); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
 }
 
-func NewConfig() *Config {
-	cfg := &Config{}
+// sets flags from one source; a secret given directly overrides its file from lower sources and vice versa
+func applySource(fs *flag.FlagSet, values map[string]string, describe func(flag string) string) error {
+	for name, value := range values {
+		if other, ok := secretFlags[name]; ok {
+			if _, both := values[other]; both {
+				return fmt.Errorf("%s and %s are mutually exclusive", describe(name), describe(other))
+			}
+			if err := fs.Set(other, ""); err != nil {
+				return err
+			}
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", describe(name), err)
+		}
+	}
+	return nil
+}
+
+// returns the flags bound to the config fields, using their current values as defaults
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	fs.StringVar(&c.ConfigFile, "config", c.ConfigFile, "path of a YAML or TOML config file")
+	fs.DurationVar(&c.ConfigWatchInterval, "config-watch-interval", c.ConfigWatchInterval, "how often the config file is checked for changes to reload, 0 to reload on SIGHUP only")
+	fs.StringVar(&c.RunAddress, "a", c.RunAddress, "address and port to run server")
+	fs.StringVar(&c.GRPCAddress, "g", c.GRPCAddress, "address and port to run gRPC server, e.g. :9090; disabled when empty")
+	fs.StringVar(&c.DatabaseURI, "d", c.DatabaseURI, "database URI")
+	fs.StringVar(&c.AccrualSystemAddress, "r", c.AccrualSystemAddress, "accrual system address")
+	fs.StringVar(&c.JWTSecret, "j", c.JWTSecret, "JWT secret key, at least 32 bytes; prefer -jwt-secret-file as flags are visible in process listings")
+	fs.StringVar(&c.DatabaseURIFile, "database-uri-file", c.DatabaseURIFile, "file holding the database URI")
+	fs.StringVar(&c.JWTSecretFile, "jwt-secret-file", c.JWTSecretFile, "file holding the JWT secret key")
+	fs.DurationVar(&c.TokenTTL, "token-ttl", c.TokenTTL, "lifetime of access tokens and sessions")
+	fs.DurationVar(&c.JWTSecretGracePeriod, "jwt-secret-grace-period", c.JWTSecretGracePeriod, "how long tokens signed with the previous JWT secret are accepted after a rotation, 0 to reject them at once")
+	fs.DurationVar(&c.AccrualPollInterval, "accrual-poll-interval", c.AccrualPollInterval, "how often orders still in processing are rechecked")
+	fs.DurationVar(&c.AccrualTimeout, "accrual-timeout", c.AccrualTimeout, "timeout of a request to the accrual system")
+	fs.DurationVar(&c.HTTPReadHeaderTimeout, "http-read-header-timeout", c.HTTPReadHeaderTimeout, "time allowed to read request headers")
+	fs.DurationVar(&c.HTTPReadTimeout, "http-read-timeout", c.HTTPReadTimeout, "time allowed to read a whole request, 0 for no limit")
+	fs.DurationVar(&c.HTTPWriteTimeout, "http-write-timeout", c.HTTPWriteTimeout, "time allowed to write a response, 0 for no limit as needed by event streams")
+	fs.DurationVar(&c.HTTPIdleTimeout, "http-idle-timeout", c.HTTPIdleTimeout, "time a keep-alive connection may stay idle, 0 for no limit")
+	fs.DurationVar(&c.ShutdownDrainDelay, "shutdown-drain-delay", c.ShutdownDrainDelay, "time between failing readiness and closing the listeners on shutdown")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time given to in-flight requests to finish on shutdown")
+	fs.IntVar(&c.DBMaxConns, "db-max-conns", c.DBMaxConns, "maximum number of database connections")
+	fs.IntVar(&c.DBMinConns, "db-min-conns", c.DBMinConns, "number of database connections kept open when idle")
+	fs.UintVar(&c.Argon2Memory, "argon2-memory", c.Argon2Memory, "argon2id memory in KiB")
+	fs.UintVar(&c.Argon2Iterations, "argon2-iterations", c.Argon2Iterations, "argon2id number of iterations")
+	fs.UintVar(&c.Argon2Parallelism, "argon2-parallelism", c.Argon2Parallelism, "argon2id degree of parallelism")
+	fs.Float64Var(&c.TwoFactorWithdrawalThreshold, "2fa-withdrawal-threshold", c.TwoFactorWithdrawalThreshold, "withdrawal sum above which a TOTP code is required")
+	fs.Var((*listValue)(&c.AdminLogins), "admin-logins", "comma-separated logins granted the admin role on registration")
+	fs.StringVar(&c.RateLimitStore, "rate-limit-store", c.RateLimitStore, "rate limit storage: memory, or postgres to share limits across replicas")
+	fs.Var(&c.LoginRateLimit, "login-rate-limit", "requests allowed per window on login routes, as limit/window, e.g. 10/1m")
+	fs.Var(&c.RegisterRateLimit, "register-rate-limit", "requests allowed per window on the registration route, as limit/window")
+	fs.Var(&c.OrdersRateLimit, "orders-rate-limit", "requests allowed per window on order routes, as limit/window")
+	fs.BoolVar(&c.OpenAPIValidate, "openapi-validate", c.OpenAPIValidate, "reject requests and log responses that break the OpenAPI document")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "log level: debug, info, warn or error")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "log format: text or json")
+	fs.StringVar(&c.TraceExporter, "trace-exporter", c.TraceExporter, "trace exporter: none, stdout, or otlp configured by OTEL_EXPORTER_OTLP_* variables")
+	fs.StringVar(&c.AdminAddress, "admin-address", c.AdminAddress, "address of the diagnostics listener serving pprof, expvar and runtime state, empty to disable it")
+
+	return fs
+}
+
+// reads a YAML or TOML config file, chosen by its extension; unknown keys are rejected
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown keys in config file %s: %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q, use .yaml, .yml or .toml", ext)
+	}
+
+	if c.JWTSecret != "" && c.JWTSecretFile != "" {
+		return fmt.Errorf("jwt_secret and jwt_secret_file in config file %s are mutually exclusive", path)
+	}
+	if c.DatabaseURI != "" && c.DatabaseURIFile != "" {
+		return fmt.Errorf("database_uri and database_uri_file in config file %s are mutually exclusive", path)
+	}
+	return nil
+}
+
+// replaces secrets configured as files with the files' contents
+func (c *Config) readSecretFiles() error {
+	if c.JWTSecretFile != "" {
+		secret, err := readSecretFile(c.JWTSecretFile)
+		if err != nil {
+			return fmt.Errorf("failed to read JWT secret file: %w", err)
+		}
+		c.JWTSecret = secret
+	}
+	if c.DatabaseURIFile != "" {
+		uri, err := readSecretFile(c.DatabaseURIFile)
+		if err != nil {
+			return fmt.Errorf("failed to read database URI file: %w", err)
+		}
+		c.DatabaseURI = uri
+	}
+	return nil
+}
+
+// reads a secret file dropping the trailing newline editors and secret stores tend to add
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// checks that the config is complete and safe to run with, reporting every problem found
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.ConfigWatchInterval >= 0, "config watch interval must not be negative")
+	check(c.RunAddress != "", "run address is required")
+	check(c.DatabaseURI != "", "database URI is required")
+	check(c.AccrualSystemAddress != "", "accrual system address is required")
+	if c.AccrualSystemAddress != "" {
+		u, err := url.Parse(c.AccrualSystemAddress)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"accrual system address %q must be an absolute http or https URL", c.AccrualSystemAddress)
+	}
+	check(c.JWTSecret != "", "JWT secret is required")
+	check(c.JWTSecret == "" || len(c.JWTSecret) >= minJWTSecretLength, "JWT secret must be at least %d bytes", minJWTSecretLength)
+
+	check(c.TokenTTL > 0, "token TTL must be positive")
+	check(c.JWTSecretGracePeriod >= 0, "JWT secret grace period must not be negative")
+	check(c.AccrualPollInterval > 0, "accrual poll interval must be positive")
+	check(c.AccrualTimeout > 0, "accrual timeout must be positive")
+	check(c.HTTPReadHeaderTimeout > 0, "HTTP read header timeout must be positive")
+	check(c.HTTPReadTimeout >= 0, "HTTP read timeout must not be negative")
+	check(c.HTTPWriteTimeout >= 0, "HTTP write timeout must not be negative")
+	check(c.HTTPIdleTimeout >= 0, "HTTP idle timeout must not be negative")
+	check(c.ShutdownDrainDelay >= 0, "shutdown drain delay must not be negative")
+	check(c.ShutdownTimeout > 0, "shutdown timeout must be positive")
+
+	check(c.DBMaxConns > 0, "database max connections must be positive")
+	check(c.DBMinConns >= 0 && c.DBMinConns <= c.DBMaxConns, "database min connections must be between 0 and max connections")
+
+	check(c.Argon2Iterations > 0, "argon2 iterations must be positive")
+	check(c.Argon2Parallelism > 0 && c.Argon2Parallelism <= 255, "argon2 parallelism must be between 1 and 255")
+	check(c.Argon2Memory >= 8*c.Argon2Parallelism, "argon2 memory must be at least 8 KiB per degree of parallelism")
+	check(c.TwoFactorWithdrawalThreshold >= 0, "2FA withdrawal threshold must not be negative")
+
+	check(c.RateLimitStore == "memory" || c.RateLimitStore == "postgres", "unknown rate limit store %q", c.RateLimitStore)
+	check(c.LoginRateLimit.valid(), "login rate limit must have a positive limit and window")
+	check(c.RegisterRateLimit.valid(), "register rate limit must have a positive limit and window")
+	check(c.OrdersRateLimit.valid(), "orders rate limit must have a positive limit and window")
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.LogLevel)) == nil, "unknown log level %q", c.LogLevel)
+	check(c.LogFormat == "text" || c.LogFormat == "json", "unknown log format %q", c.LogFormat)
+	check(c.TraceExporter == "none" || c.TraceExporter == "stdout" || c.TraceExporter == "otlp", "unknown trace exporter %q", c.TraceExporter)
+
+	check(c.GRPCAddress == "" || c.GRPCAddress != c.RunAddress, "gRPC address must differ from the run address")
+	check(c.AdminAddress == "" || c.AdminAddress != c.RunAddress, "admin address must differ from the run address")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
 
-	flag.StringVar(&cfg.RunAddress, "a", ":8080", "address and port to run server")
-	flag.StringVar(&cfg.DatabaseURI, "d", "", "database URI")
-	flag.StringVar(&cfg.AccrualSystemAddress, "r", "", "accrual system address")
-	flag.StringVar(&cfg.JWTSecret, "j", "your-secret-key", "JWT secret key")
-	flag.Parse()
+// returns a copy of the config safe to show, with secrets replaced
+func (c *Config) Redacted() Config {
+	out := *c
+	out.AdminLogins = append([]string(nil), c.AdminLogins...)
+	if out.JWTSecret != "" {
+		out.JWTSecret = redacted
+	}
+	out.DatabaseURI = redactURI(c.DatabaseURI)
+	return out
+}
+
+// logs the config with secrets replaced
+func (c *Config) LogValue() slog.Value {
+	return slog.AnyValue(c.Redacted())
+}
 
-	// check environment variables
-	if envRunAddr := os.Getenv("RUN_ADDRESS"); envRunAddr != "" {
-		cfg.RunAddress = envRunAddr
+// hides the password of a URI, or the whole value if it does not parse as a URL
+func redactURI(uri string) string {
+	if uri == "" {
+		return ""
 	}
-	if envDBURI := os.Getenv("DATABASE_URI"); envDBURI != "" {
-		cfg.DatabaseURI = envDBURI
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		// key=value connection strings may carry a password anywhere
+		return redacted
 	}
-	if envAccrualAddr := os.Getenv("ACCRUAL_SYSTEM_ADDRESS"); envAccrualAddr != "" {
-		cfg.AccrualSystemAddress = envAccrualAddr
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
 	}
-	if envJWTSecret := os.Getenv("JWT_SECRET"); envJWTSecret != "" {
-		cfg.JWTSecret = envJWTSecret
+	q := u.Query()
+	if q.Has("password") {
+		q.Set("password", redacted)
+		u.RawQuery = q.Encode()
 	}
+	return u.String()
+}
+
+// represents a comma-separated list flag
+type listValue []string
 
-	return cfg
+// joins the list items
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+// replaces the list with the given comma-separated items
+func (l *listValue) Set(s string) error {
+	*l = splitList(s)
+	return nil
+}
+
+// splits a comma-separated list dropping empty items
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
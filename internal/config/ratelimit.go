@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// represents a rate limit of Limit requests per Window, written as limit/window, e.g. 10/1m
+type RateLimit struct {
+	Limit  int
+	Window time.Duration
+}
+
+// formats the rate limit as limit/window
+func (r RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", r.Limit, r.Window)
+}
+
+// sets the rate limit from a flag or an environment variable
+func (r *RateLimit) Set(s string) error {
+	return r.UnmarshalText([]byte(s))
+}
+
+// formats the rate limit for config dumps
+func (r RateLimit) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// parses a rate limit written as limit/window
+func (r *RateLimit) UnmarshalText(text []byte) error {
+	limit, window, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("rate limit %q must be written as limit/window, e.g. 10/1m", text)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if err != nil {
+		return fmt.Errorf("invalid rate limit %q: %w", text, err)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil {
+		return fmt.Errorf("invalid rate limit %q: %w", text, err)
+	}
+	r.Limit, r.Window = n, d
+	return nil
+}
+
+// reports whether the rate limit can be enforced
+func (r RateLimit) valid() bool {
+	return r.Limit > 0 && r.Window > 0
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"time"
+)
+
+// fields applied to running components when the config is reloaded, changes to others need a restart
+var reloadableFields = map[string]bool{
+	"AccrualPollInterval": true,
+	"LoginRateLimit":      true,
+	"RegisterRateLimit":   true,
+	"OrdersRateLimit":     true,
+	"LogLevel":            true,
+}
+
+// loads the config again from every source; the result keeps the current values of fields that
+// cannot change while running, and the names of those that were changed are returned as ignored
+func (c *Config) Reload() (*Config, []string, error) {
+	next, err := load(os.Args[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := *c
+	current := reflect.ValueOf(&out).Elem()
+	loaded := reflect.ValueOf(next).Elem()
+	var ignored []string
+	for i := 0; i < current.NumField(); i++ {
+		name := current.Type().Field(i).Name
+		if c.reloadable(name) {
+			current.Field(i).Set(loaded.Field(i))
+			continue
+		}
+		if !reflect.DeepEqual(current.Field(i).Interface(), loaded.Field(i).Interface()) {
+			ignored = append(ignored, name)
+		}
+	}
+	return &out, ignored, nil
+}
+
+// reports whether a field may change while running; secrets may when they are read from files
+func (c *Config) reloadable(field string) bool {
+	switch field {
+	case "JWTSecret":
+		return c.JWTSecretFile != ""
+	case "DatabaseURI":
+		return c.DatabaseURIFile != ""
+	}
+	return reloadableFields[field]
+}
+
+// signals changed whenever the modification time or size of the file changes, checking every interval
+func WatchFile(path string, interval time.Duration, changed chan<- struct{}) {
+	last, lastErr := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(path)
+		// a file being replaced may be missing for a moment, wait for it to reappear
+		if err != nil {
+			continue
+		}
+		if lastErr == nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last, lastErr = info, nil
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package diagnostics
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	runtimepprof "runtime/pprof"
+	"time"
+
+	"gophermart/internal/config"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// time the process started, reported as uptime
+var started = time.Now()
+
+// represents the runtime state of the process
+type runtimeState struct {
+	GoVersion    string `json:"go_version"`
+	Uptime       string `json:"uptime"`
+	Goroutines   int    `json:"goroutines"`
+	GOMAXPROCS   int    `json:"gomaxprocs"`
+	HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	NumGC        uint32 `json:"num_gc"`
+	PauseTotalNs uint64 `json:"gc_pause_total_ns"`
+}
+
+// creates the diagnostics handler reporting the config in effect; it must only be served on the admin listener,
+// never on the public address
+func NewHandler(cfg func() *config.Config, orderService *services.OrderService) http.Handler {
+	mux := http.NewServeMux()
+
+	// profiles, e.g. go tool pprof http://localhost:6060/debug/pprof/profile?seconds=30;
+	// pprof.Cmdline is left out because argv may carry -j and -d
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	mux.HandleFunc("/debug/vars", serveVars)
+
+	// full stacks of every goroutine in plain text
+	mux.HandleFunc("/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		runtimepprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+
+	mux.HandleFunc("/debug/config", func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJSON(w, http.StatusOK, cfg().Redacted())
+	})
+
+	mux.HandleFunc("/debug/worker", func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJSON(w, http.StatusOK, orderService.WorkerState())
+	})
+
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		utils.SendJSON(w, http.StatusOK, runtimeState{
+			GoVersion:    runtime.Version(),
+			Uptime:       time.Since(started).Round(time.Second).String(),
+			Goroutines:   runtime.NumGoroutine(),
+			GOMAXPROCS:   runtime.GOMAXPROCS(0),
+			HeapAlloc:    mem.HeapAlloc,
+			HeapObjects:  mem.HeapObjects,
+			NumGC:        mem.NumGC,
+			PauseTotalNs: mem.PauseTotalNs,
+		})
+	})
+
+	return mux
+}
+
+// serves expvar variables like expvar.Handler, except cmdline, which may carry secrets
+func serveVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			return
+		}
+		if !first {
+			fmt.Fprintf(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "\n}\n")
+}
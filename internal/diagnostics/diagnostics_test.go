@@ -0,0 +1,35 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCommandLineNotExposed(t *testing.T) {
+	h := NewHandler(nil, nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil))
+	if rec.Code == http.StatusOK {
+		t.Errorf("/debug/pprof/cmdline status = %d, want an error", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/debug/vars status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("/debug/vars is not JSON: %v", err)
+	}
+	if _, ok := vars["cmdline"]; ok {
+		t.Error("/debug/vars exposes cmdline")
+	}
+	if _, ok := vars["memstats"]; !ok {
+		t.Error("/debug/vars misses memstats")
+	}
+}
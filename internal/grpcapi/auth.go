@@ -0,0 +1,127 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents an interceptor authenticating calls by a JWT in the authorization metadata
+type authInterceptor struct {
+	signingKey     *utils.SigningKey
+	sessionService *services.SessionService
+	// methods callable without a token
+	public map[string]bool
+}
+
+// authenticates unary calls
+func (a *authInterceptor) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx = utils.WithClientIP(ctx, peerIP(ctx))
+	if a.public[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// authenticates streaming calls
+func (a *authInterceptor) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx := utils.WithClientIP(ss.Context(), peerIP(ss.Context()))
+	if a.public[info.FullMethod] {
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// checks the access token and its session, adding the user to the context
+func (a *authInterceptor) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return nil, newError(http.StatusUnauthorized, utils.CodeAuthRequired, "Authorization metadata is required")
+	}
+
+	token, ok := strings.CutPrefix(values[0], "Bearer ")
+	if !ok {
+		return nil, newError(http.StatusUnauthorized, utils.CodeAuthInvalidHeader, "Invalid authorization metadata format")
+	}
+
+	claims, err := utils.ParseToken(token, a.signingKey)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to parse token", "error", err)
+		return nil, newError(http.StatusUnauthorized, utils.CodeAuthInvalidToken, "Invalid token")
+	}
+	if claims.Purpose != "" || claims.SessionID == "" {
+		return nil, newError(http.StatusUnauthorized, utils.CodeAuthInvalidToken, "Invalid token")
+	}
+
+	if err := a.sessionService.Validate(ctx, claims.UserID, claims.SessionID, peerIP(ctx)); err != nil {
+		slog.ErrorContext(ctx, "Failed to validate session", "error", err)
+		return nil, serviceError(err)
+	}
+
+	ctx = utils.WithUserID(ctx, claims.UserID)
+	ctx = utils.WithRoles(ctx, claims.Roles)
+	ctx = utils.WithSessionID(ctx, claims.SessionID)
+	return ctx, nil
+}
+
+// represents a server stream with an enriched context
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// returns the enriched context
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// gets the client IP address of a call
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// gets the client user agent of a call
+func userAgent(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("user-agent"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// gets the authenticated user ID of a call
+func userID(ctx context.Context) (int64, error) {
+	id, ok := utils.GetUserID(ctx)
+	if !ok || id == 0 {
+		return 0, newError(http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+	}
+	return id, nil
+}
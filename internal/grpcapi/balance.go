@@ -0,0 +1,75 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"gophermart/internal/grpcapi/pb"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents the gRPC balance service
+type balanceServer struct {
+	pb.UnimplementedBalanceServiceServer
+	balanceService *services.BalanceService
+}
+
+// gets the user balance
+func (s *balanceServer) GetBalance(ctx context.Context, req *pb.GetBalanceRequest) (*pb.Balance, error) {
+	id, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := s.balanceService.GetBalance(ctx, int(id))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get balance", "error", err)
+		return nil, newError(http.StatusInternalServerError, utils.CodeInternal, "Failed to get balance")
+	}
+
+	return &pb.Balance{Current: balance.Current, Withdrawn: balance.Withdrawn}, nil
+}
+
+// withdraws points from the user balance
+func (s *balanceServer) Withdraw(ctx context.Context, req *pb.WithdrawRequest) (*pb.WithdrawResponse, error) {
+	id, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.balanceService.CreateWithdrawal(ctx, int(id), req.GetOrder(), req.GetSum(), req.GetTotpCode())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to create withdrawal", "error", err)
+		return nil, serviceError(err)
+	}
+
+	return &pb.WithdrawResponse{}, nil
+}
+
+// gets the user withdrawal history
+func (s *balanceServer) ListWithdrawals(ctx context.Context, req *pb.ListWithdrawalsRequest) (*pb.ListWithdrawalsResponse, error) {
+	id, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	withdrawals, err := s.balanceService.GetWithdrawals(ctx, int(id))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get withdrawals", "error", err)
+		return nil, newError(http.StatusInternalServerError, utils.CodeInternal, "Failed to get withdrawals")
+	}
+
+	resp := &pb.ListWithdrawalsResponse{}
+	for _, w := range withdrawals {
+		resp.Withdrawals = append(resp.Withdrawals, &pb.Withdrawal{
+			Order:       w.Order,
+			Sum:         w.Sum,
+			ProcessedAt: timestamppb.New(w.CreatedAt),
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package grpcapi
+
+import (
+	"net/http"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"gophermart/internal/handlers"
+	"gophermart/internal/utils"
+)
+
+// domain of the ErrorInfo details attached to error statuses
+const errorDomain = "gophermart"
+
+// converts a service error to a gRPC status carrying its stable error code
+func serviceError(err error) error {
+	return problemStatus(handlers.ServiceErrorProblem(err))
+}
+
+// creates a gRPC status error with a stable error code
+func newError(httpStatus int, code, detail string) error {
+	return problemStatus(utils.NewProblem(httpStatus, code, detail))
+}
+
+// converts a problem to a gRPC status with ErrorInfo and field violation details
+func problemStatus(p *utils.Problem) error {
+	st := status.New(grpcCode(p.Status), p.Detail)
+
+	info := &errdetails.ErrorInfo{Reason: p.Code, Domain: errorDomain}
+	withDetails, err := st.WithDetails(info)
+	if err != nil {
+		return st.Err()
+	}
+
+	if len(p.Errors) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, f := range p.Errors {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Message,
+			})
+		}
+		if st, err := withDetails.WithDetails(badRequest); err == nil {
+			withDetails = st
+		}
+	}
+
+	return withDetails.Err()
+}
+
+// maps an HTTP status to the closest gRPC code
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusPaymentRequired:
+		return codes.FailedPrecondition
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	default:
+		return codes.Internal
+	}
+}
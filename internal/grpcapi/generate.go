@@ -0,0 +1,3 @@
+package grpcapi
+
+//go:generate protoc -I ../../api/proto --go_out=pb --go_opt=module=gophermart/internal/grpcapi/pb --go-grpc_out=pb --go-grpc_opt=module=gophermart/internal/grpcapi/pb user.proto order.proto balance.proto
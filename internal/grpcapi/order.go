@@ -0,0 +1,169 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"gophermart/internal/grpcapi/pb"
+	"gophermart/internal/models"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// how often the event log is polled for events written by other instances
+const watchPollInterval = 5 * time.Second
+
+// represents the gRPC order service
+type orderServer struct {
+	pb.UnimplementedOrderServiceServer
+	orderService *services.OrderService
+	eventService *services.OrderEventService
+}
+
+// uploads an order
+func (s *orderServer) UploadOrder(ctx context.Context, req *pb.UploadOrderRequest) (*pb.UploadOrderResponse, error) {
+	id, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	number := strings.TrimSpace(req.GetNumber())
+	if number == "" {
+		return nil, serviceError(services.NewValidationError("number", "required", "is required"))
+	}
+
+	err = s.orderService.CreateOrder(ctx, int(id), number)
+	if errors.Is(err, services.ErrOrderExists) {
+		return &pb.UploadOrderResponse{AlreadyUploaded: true}, nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to upload order", "error", err)
+		return nil, serviceError(err)
+	}
+
+	return &pb.UploadOrderResponse{}, nil
+}
+
+// gets a list of user orders
+func (s *orderServer) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	id, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.orderService.GetUserOrders(ctx, int(id))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get user orders", "error", err)
+		return nil, newError(http.StatusInternalServerError, utils.CodeInternal, "Failed to get user orders")
+	}
+
+	resp := &pb.ListOrdersResponse{}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, orderMessage(order))
+	}
+	return resp, nil
+}
+
+// gets a user order by number
+func (s *orderServer) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.Order, error) {
+	id, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	orders, err := s.orderService.GetUserOrders(ctx, int(id))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get user orders", "error", err)
+		return nil, newError(http.StatusInternalServerError, utils.CodeInternal, "Failed to get user orders")
+	}
+
+	for _, order := range orders {
+		if order.Number == req.GetNumber() {
+			return orderMessage(order), nil
+		}
+	}
+	return nil, newError(http.StatusNotFound, utils.CodeOrderNotFound, "Order not found")
+}
+
+// streams user order events, resuming after the requested event ID
+func (s *orderServer) WatchOrders(req *pb.WatchOrdersRequest, stream pb.OrderService_WatchOrdersServer) error {
+	ctx := stream.Context()
+	id, err := userID(ctx)
+	if err != nil {
+		return err
+	}
+
+	// subscribe before reading the log so that no event falls in between
+	wake, unsubscribe := s.eventService.Subscribe(id)
+	defer unsubscribe()
+
+	lastID := req.GetLastEventId()
+	if req.LastEventId == nil {
+		lastID, err = s.eventService.LatestID(ctx, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to get latest order event", "error", err)
+			return newError(http.StatusInternalServerError, utils.CodeInternal, "Failed to get order events")
+		}
+	}
+
+	poll := time.NewTicker(watchPollInterval)
+	defer poll.Stop()
+
+	for {
+		for {
+			events, err := s.eventService.Since(ctx, id, lastID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				slog.ErrorContext(ctx, "Failed to get order events", "error", err)
+				return newError(http.StatusInternalServerError, utils.CodeInternal, "Failed to get order events")
+			}
+			if len(events) == 0 {
+				break
+			}
+
+			for _, event := range events {
+				if err := stream.Send(orderEventMessage(event)); err != nil {
+					return err
+				}
+				lastID = event.ID
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// converts an order to its message
+func orderMessage(order models.Order) *pb.Order {
+	return &pb.Order{
+		Number:     order.Number,
+		Status:     order.Status,
+		Accrual:    order.Accrual,
+		UploadedAt: timestamppb.New(order.CreatedAt),
+	}
+}
+
+// converts an order event to its message
+func orderEventMessage(event models.OrderEvent) *pb.OrderEvent {
+	return &pb.OrderEvent{
+		Id:        event.ID,
+		Type:      event.Type,
+		Order:     event.Order,
+		Status:    event.Status,
+		Amount:    event.Amount,
+		CreatedAt: timestamppb.New(event.CreatedAt),
+	}
+}
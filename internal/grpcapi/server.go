@@ -0,0 +1,51 @@
+package grpcapi
+
+import (
+	"google.golang.org/grpc"
+
+	"gophermart/internal/grpcapi/pb"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// creates a gRPC server exposing the user, order and balance services
+func NewServer(
+	userService *services.UserService,
+	twoFactorService *services.TwoFactorService,
+	sessionService *services.SessionService,
+	orderService *services.OrderService,
+	orderEventService *services.OrderEventService,
+	balanceService *services.BalanceService,
+	signingKey *utils.SigningKey,
+) *grpc.Server {
+	auth := &authInterceptor{
+		signingKey:     signingKey,
+		sessionService: sessionService,
+		public: map[string]bool{
+			pb.UserService_Register_FullMethodName:       true,
+			pb.UserService_Login_FullMethodName:          true,
+			pb.UserService_LoginTwoFactor_FullMethodName: true,
+		},
+	}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(auth.unary),
+		grpc.StreamInterceptor(auth.stream),
+	)
+
+	pb.RegisterUserServiceServer(server, &userServer{
+		userService:      userService,
+		twoFactorService: twoFactorService,
+		sessionService:   sessionService,
+		signingKey:       signingKey,
+	})
+	pb.RegisterOrderServiceServer(server, &orderServer{
+		orderService: orderService,
+		eventService: orderEventService,
+	})
+	pb.RegisterBalanceServiceServer(server, &balanceServer{
+		balanceService: balanceService,
+	})
+
+	return server
+}
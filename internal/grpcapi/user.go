@@ -0,0 +1,92 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/grpcapi/pb"
+	"gophermart/internal/models"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents the gRPC user service
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	userService      *services.UserService
+	twoFactorService *services.TwoFactorService
+	sessionService   *services.SessionService
+	signingKey       *utils.SigningKey
+}
+
+// registers a new user
+func (s *userServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.AuthResponse, error) {
+	user, err := s.userService.Register(ctx, req.GetLogin(), req.GetPassword())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to register user", "error", err)
+		return nil, serviceError(err)
+	}
+
+	return s.startSession(ctx, user)
+}
+
+// authenticates a user
+func (s *userServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.AuthResponse, error) {
+	user, err := s.userService.Authenticate(ctx, req.GetLogin(), req.GetPassword())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to authenticate user", "error", err)
+		return nil, serviceError(err)
+	}
+
+	// users with 2FA enabled get a short-lived token for the second step instead
+	if user.TOTPEnabled {
+		mfaToken, err := utils.GenerateMFAToken(user.ID, s.signingKey)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to generate MFA token", "error", err)
+			return nil, newError(http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
+		}
+		return &pb.AuthResponse{TwoFactorRequired: true, MfaToken: mfaToken}, nil
+	}
+
+	return s.startSession(ctx, user)
+}
+
+// completes a login for users with two-factor authentication enabled
+func (s *userServer) LoginTwoFactor(ctx context.Context, req *pb.LoginTwoFactorRequest) (*pb.AuthResponse, error) {
+	claims, err := utils.ParseToken(req.GetMfaToken(), s.signingKey)
+	if err != nil || claims.Purpose != utils.TokenPurposeMFA {
+		slog.ErrorContext(ctx, "Failed to parse MFA token", "error", err)
+		return nil, newError(http.StatusUnauthorized, utils.CodeAuthInvalidToken, "Invalid token")
+	}
+
+	if err := s.twoFactorService.Verify(ctx, claims.UserID, req.GetCode(), req.GetRecoveryCode()); err != nil {
+		slog.ErrorContext(ctx, "Failed to verify two-factor code", "error", err)
+		return nil, serviceError(err)
+	}
+
+	user, err := s.userService.GetByID(ctx, claims.UserID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get user", "error", err)
+		return nil, serviceError(err)
+	}
+
+	return s.startSession(ctx, user)
+}
+
+// creates a session and an access token for it
+func (s *userServer) startSession(ctx context.Context, user *models.User) (*pb.AuthResponse, error) {
+	session, err := s.sessionService.Create(ctx, user.ID, userAgent(ctx), peerIP(ctx))
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to start session", "error", err)
+		return nil, newError(http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
+	}
+
+	token, err := utils.GenerateToken(user.ID, session.ID, user.Roles, s.signingKey, s.sessionService.TTL())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to generate token", "error", err)
+		return nil, newError(http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
+	}
+
+	return &pb.AuthResponse{AccessToken: token}, nil
+}
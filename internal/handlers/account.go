@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/models"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents an account handler
+type AccountHandler struct {
+	accountService *services.AccountService
+}
+
+// creates a new account handler
+func NewAccountHandler(accountService *services.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+// represents an account deletion request
+type deleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// exports user personal data as JSON or a ZIP archive
+func (h *AccountHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "zip" {
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidParameter, "Unsupported export format")
+		return
+	}
+
+	export, err := h.accountService.Export(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to export user data", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Failed to export user data")
+		return
+	}
+
+	filename := fmt.Sprintf("gophermart-export-%s", export.ExportedAt.Format("20060102-150405"))
+
+	if format != "zip" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		utils.SendJSON(w, http.StatusOK, export)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeExportZip(w, export); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write export archive", "error", err)
+	}
+}
+
+// deletes the user account
+func (h *AccountHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	var req deleteAccountRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	err := h.accountService.Delete(r.Context(), userID, req.Password, r.Header.Get(TOTPCodeHeader))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to delete user", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writes an export as a ZIP archive with one JSON file per section
+func writeExportZip(w http.ResponseWriter, export *models.UserExport) error {
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"balance.json", export.Balance},
+		{"orders.json", export.Orders},
+		{"withdrawals.json", export.Withdrawals},
+		{"balance_history.json", export.BalanceHistory},
+		{"sessions.json", export.Sessions},
+	}
+
+	for _, file := range files {
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     file.name,
+			Method:   zip.Deflate,
+			Modified: export.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents an admin handler
+type AdminHandler struct {
+	adminService *services.AdminService
+}
+
+// creates a new admin handler
+func NewAdminHandler(adminService *services.AdminService) *AdminHandler {
+	return &AdminHandler{
+		adminService: adminService,
+	}
+}
+
+// represents a balance adjustment request
+type balanceAdjustmentRequest struct {
+	Amount float32 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+// represents an order status override request
+type orderStatusRequest struct {
+	Status  string  `json:"status"`
+	Accrual float32 `json:"accrual"`
+}
+
+// gets a user by login
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	login := r.PathValue("login")
+
+	user, err := h.adminService.GetUser(r.Context(), login)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get user", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendJSON(w, http.StatusOK, user)
+}
+
+// adjusts a user balance
+func (h *AdminHandler) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := utils.GetUserID(r.Context())
+	if !ok || adminID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	login := r.PathValue("login")
+
+	var req balanceAdjustmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	balance, err := h.adminService.AdjustBalance(r.Context(), adminID, login, req.Amount, req.Reason)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to adjust balance", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendJSON(w, http.StatusOK, balance)
+}
+
+// overrides an order status
+func (h *AdminHandler) OverrideOrderStatus(w http.ResponseWriter, r *http.Request) {
+	number := r.PathValue("number")
+
+	var req orderStatusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	order, err := h.adminService.OverrideOrderStatus(r.Context(), number, req.Status, req.Accrual)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to override order status", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendJSON(w, http.StatusOK, order)
+}
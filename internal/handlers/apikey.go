@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gophermart/internal/models"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents an API key handler
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+// creates a new API key handler
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// represents an API key creation request
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// creates an API key
+func (h *APIKeyHandler) Create(w http.ResponseWriter, r *http.Request) {
+	adminID, ok := utils.GetUserID(r.Context())
+	if !ok || adminID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	var req createAPIKeyRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	key, err := h.apiKeyService.Create(r.Context(), adminID, req.Name, req.Scopes)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to create api key", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendJSON(w, http.StatusCreated, key)
+}
+
+// gets a list of API keys
+func (h *APIKeyHandler) List(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.apiKeyService.List(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get api keys", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	if len(keys) == 0 {
+		utils.SendJSON(w, http.StatusOK, []models.APIKey{})
+		return
+	}
+
+	utils.SendJSON(w, http.StatusOK, keys)
+}
+
+// revokes an API key
+func (h *APIKeyHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		utils.SendError(w, r, http.StatusNotFound, utils.CodeAPIKeyNotFound, "API key not found")
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(r.Context(), id); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to revoke api key", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
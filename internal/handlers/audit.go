@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"gophermart/internal/models"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents an audit log handler
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+// creates a new audit log handler
+func NewAuditHandler(auditService *services.AuditService) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+	}
+}
+
+// gets audit events filtered by the type, user_id, after_id and limit query parameters
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{Type: query.Get("type")}
+
+	validation := &services.ValidationError{}
+	parseInt := func(name string, dst *int64) {
+		if value := query.Get(name); value != "" {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				validation.Add(name, "invalid_type", "must be an integer")
+				return
+			}
+			*dst = n
+		}
+	}
+	var limit int64
+	parseInt("user_id", &filter.UserID)
+	parseInt("after_id", &filter.AfterID)
+	parseInt("limit", &limit)
+	filter.Limit = int(limit)
+	if err := validation.OrNil(); err != nil {
+		SendServiceError(w, r, err)
+		return
+	}
+
+	events, err := h.auditService.List(r.Context(), filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get audit events", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	utils.SendJSON(w, http.StatusOK, events)
+}
+
+// verifies the audit hash chain
+func (h *AuditHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	result, err := h.auditService.Verify(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to verify audit log", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendJSON(w, http.StatusOK, result)
+}
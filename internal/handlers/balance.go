@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"encoding/json"
+	"log/slog"
 	"net/http"
 
 	"gophermart/internal/models"
@@ -25,14 +25,14 @@ func NewBalanceHandler(balanceService *services.BalanceService) *BalanceHandler
 func (h *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	userID, ok := utils.GetUserID(r.Context())
 	if !ok || userID == 0 {
-		utils.SendError(w, http.StatusUnauthorized, "User not authenticated")
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
 		return
 	}
 
 	balance, err := h.balanceService.GetBalance(r.Context(), int(userID))
 	if err != nil {
-		utils.LogError("Failed to get balance: %v", err)
-		utils.SendError(w, http.StatusInternalServerError, "Failed to get balance")
+		slog.ErrorContext(r.Context(), "Failed to get balance", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Failed to get balance")
 		return
 	}
 
@@ -49,28 +49,19 @@ type withdrawalRequest struct {
 func (h *BalanceHandler) CreateWithdrawal(w http.ResponseWriter, r *http.Request) {
 	userID, ok := utils.GetUserID(r.Context())
 	if !ok || userID == 0 {
-		utils.SendError(w, http.StatusUnauthorized, "User not authenticated")
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
 		return
 	}
 
 	var req withdrawalRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogError("Failed to decode request: %v", err)
-		utils.SendError(w, http.StatusBadRequest, "Invalid request format")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	err := h.balanceService.CreateWithdrawal(r.Context(), int(userID), req.Order, req.Sum)
+	err := h.balanceService.CreateWithdrawal(r.Context(), int(userID), req.Order, req.Sum, r.Header.Get(TOTPCodeHeader))
 	if err != nil {
-		utils.LogError("Failed to create withdrawal: %v", err)
-		switch err {
-		case services.ErrInvalidOrderNumber:
-			utils.SendError(w, http.StatusUnprocessableEntity, "Invalid order number")
-		case services.ErrInsufficientFunds:
-			utils.SendError(w, http.StatusUnprocessableEntity, "Insufficient funds")
-		default:
-			utils.SendError(w, http.StatusInternalServerError, "Failed to create withdrawal")
-		}
+		slog.ErrorContext(r.Context(), "Failed to create withdrawal", "error", err)
+		SendServiceError(w, r, err)
 		return
 	}
 
@@ -80,14 +71,14 @@ func (h *BalanceHandler) CreateWithdrawal(w http.ResponseWriter, r *http.Request
 func (h *BalanceHandler) GetWithdrawals(w http.ResponseWriter, r *http.Request) {
 	userID, ok := utils.GetUserID(r.Context())
 	if !ok || userID == 0 {
-		utils.SendError(w, http.StatusUnauthorized, "User not authenticated")
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
 		return
 	}
 
 	withdrawals, err := h.balanceService.GetWithdrawals(r.Context(), int(userID))
 	if err != nil {
-		utils.LogError("Failed to get withdrawals: %v", err)
-		utils.SendError(w, http.StatusInternalServerError, "Failed to get withdrawals")
+		slog.ErrorContext(r.Context(), "Failed to get withdrawals", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Failed to get withdrawals")
 		return
 	}
 
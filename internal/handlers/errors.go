@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents how a service error is reported to clients
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+	detail string
+}
+
+// maps service errors to HTTP statuses and stable error codes
+var errorMappings = []errorMapping{
+	{services.ErrInvalidCredentials, http.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid login or password"},
+	{services.ErrUserExists, http.StatusConflict, utils.CodeUserLoginTaken, "Login is already taken"},
+	{services.ErrUserNotFound, http.StatusNotFound, utils.CodeUserNotFound, "User not found"},
+
+	{services.ErrInvalidOrderNumber, http.StatusUnprocessableEntity, utils.CodeOrderLuhnInvalid, "Order number fails the Luhn check"},
+	{services.ErrOrderExistsForOtherUser, http.StatusConflict, utils.CodeOrderOwnedByOther, "Order was uploaded by another user"},
+	{services.ErrOrderNotFound, http.StatusNotFound, utils.CodeOrderNotFound, "Order not found"},
+	{services.ErrInvalidOrderState, http.StatusUnprocessableEntity, utils.CodeOrderStatusInvalid, "Invalid order status or accrual"},
+
+	{services.ErrInsufficientFunds, http.StatusPaymentRequired, utils.CodeBalanceInsufficient, "Insufficient funds"},
+	{services.ErrInvalidAmount, http.StatusBadRequest, utils.CodeBalanceAmountInvalid, "Invalid amount"},
+
+	{services.ErrTwoFactorRequired, http.StatusForbidden, utils.CodeTwoFactorRequired, "Two-factor code required"},
+	{services.ErrInvalidTwoFactorCode, http.StatusForbidden, utils.CodeTwoFactorCodeInvalid, "Invalid two-factor code"},
+	{services.ErrTwoFactorNotEnrolled, http.StatusConflict, utils.CodeTwoFactorNotEnrolled, "Two-factor authentication is not enrolled"},
+	{services.ErrTwoFactorAlreadyEnabled, http.StatusConflict, utils.CodeTwoFactorAlreadyEnabled, "Two-factor authentication is already enabled"},
+	{services.ErrTwoFactorLocked, http.StatusTooManyRequests, utils.CodeTwoFactorLocked, "Too many invalid two-factor codes, try again later"},
+
+	{services.ErrInvalidAPIKey, http.StatusUnauthorized, utils.CodeAPIKeyInvalid, "Invalid API key"},
+	{services.ErrAPIKeyNotFound, http.StatusNotFound, utils.CodeAPIKeyNotFound, "API key not found"},
+	{services.ErrInvalidScope, http.StatusBadRequest, utils.CodeAPIKeyScopeInvalid, "Invalid API key scope"},
+	{services.ErrInvalidKeyName, http.StatusBadRequest, utils.CodeAPIKeyNameInvalid, "Invalid API key name"},
+	{services.ErrOnBehalfRequired, http.StatusBadRequest, utils.CodeAPIKeyOnBehalfRequired, "X-On-Behalf-Of header is required"},
+
+	{services.ErrSessionNotFound, http.StatusNotFound, utils.CodeSessionNotFound, "Session not found"},
+	{services.ErrSessionRevoked, http.StatusUnauthorized, utils.CodeAuthSessionEnded, "Session is terminated"},
+}
+
+// sends the problem response matching a service error
+func SendServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	utils.SendProblem(w, r, ServiceErrorProblem(err))
+}
+
+// builds the problem matching a service error, shared by every transport
+func ServiceErrorProblem(err error) *utils.Problem {
+	var validation *services.ValidationError
+	if errors.As(err, &validation) {
+		p := utils.NewProblem(http.StatusBadRequest, utils.CodeValidationFailed, "Request validation failed")
+		p.Errors = validation.Fields
+		return p
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return utils.NewProblem(m.status, m.code, m.detail)
+		}
+	}
+
+	return utils.NewProblem(http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
+}
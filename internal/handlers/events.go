@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+const (
+	// how often the event log is polled for events written by other instances
+	orderEventPollInterval = 5 * time.Second
+	// how often an idle stream sends a comment to keep connections open
+	orderEventHeartbeatInterval = 15 * time.Second
+	// client reconnection delay suggested to browsers
+	orderEventRetry = 3 * time.Second
+)
+
+// represents an order event stream handler
+type OrderEventHandler struct {
+	eventService *services.OrderEventService
+}
+
+// creates a new order event stream handler
+func NewOrderEventHandler(eventService *services.OrderEventService) *OrderEventHandler {
+	return &OrderEventHandler{
+		eventService: eventService,
+	}
+}
+
+// streams user order events as server-sent events, resuming after Last-Event-ID
+func (h *OrderEventHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	// subscribe before reading the log so that no event falls in between
+	wake, unsubscribe := h.eventService.Subscribe(userID)
+	defer unsubscribe()
+
+	var lastID int64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil || id < 0 {
+			utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidParameter, "Last-Event-ID must be an event ID")
+			return
+		}
+		lastID = id
+	} else {
+		id, err := h.eventService.LatestID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to get latest order event", "error", err)
+			utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Failed to get order events")
+			return
+		}
+		lastID = id
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	fmt.Fprintf(w, "retry: %d\n\n", orderEventRetry.Milliseconds())
+	if err := rc.Flush(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to flush order event stream", "error", err)
+		return
+	}
+
+	poll := time.NewTicker(orderEventPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(orderEventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		sent, err := h.sendEvents(w, r, userID, &lastID)
+		if err != nil {
+			if r.Context().Err() != nil {
+				return
+			}
+			slog.ErrorContext(r.Context(), "Failed to send order events", "error", err)
+			return
+		}
+		if sent {
+			heartbeat.Reset(orderEventHeartbeatInterval)
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-wake:
+		case <-poll.C:
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writes the events recorded after lastID, advancing it; reports whether any were sent
+func (h *OrderEventHandler) sendEvents(w http.ResponseWriter, r *http.Request, userID int64, lastID *int64) (bool, error) {
+	sent := false
+	for {
+		events, err := h.eventService.Since(r.Context(), userID, *lastID)
+		if err != nil {
+			return sent, err
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return sent, err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+				return sent, err
+			}
+			*lastID = event.ID
+		}
+		sent = true
+	}
+
+	if !sent {
+		return false, nil
+	}
+	return true, http.NewResponseController(w).Flush()
+}
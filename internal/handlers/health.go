@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gophermart/internal/models"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents a health handler
+type HealthHandler struct {
+	healthService *services.HealthService
+}
+
+// creates a new health handler
+func NewHealthHandler(healthService *services.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// answers liveness probes
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	utils.SendJSON(w, http.StatusOK, h.healthService.Liveness())
+}
+
+// answers readiness probes, a degraded service is still ready
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.healthService.Readiness(r.Context())
+
+	status := http.StatusOK
+	if report.Status == models.HealthStatusFailed {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	utils.SendJSON(w, status, report)
+}
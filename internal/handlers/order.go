@@ -1,14 +1,19 @@
 package handlers
 
 import (
-	"io"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"gophermart/internal/models"
 	"gophermart/internal/services"
 	"gophermart/internal/utils"
 )
 
+// maximum size of a batch upload body, enough for the largest batch as JSON
+const maxOrderBatchBodySize = services.MaxOrderBatchSize * 32
+
 // represents an order handler
 type OrderHandler struct {
 	orderService *services.OrderService
@@ -25,50 +30,84 @@ func NewOrderHandler(orderService *services.OrderService) *OrderHandler {
 func (h *OrderHandler) UploadOrder(w http.ResponseWriter, r *http.Request) {
 	userID, ok := utils.GetUserID(r.Context())
 	if !ok || userID == 0 {
-		utils.SendError(w, http.StatusUnauthorized, "User not authenticated")
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
 		return
 	}
 
-	orderNumber, err := io.ReadAll(r.Body)
-	if err != nil {
-		utils.LogError("Failed to read request body: %v", err)
-		utils.SendError(w, http.StatusBadRequest, "Failed to read request body")
+	orderNumber, ok := readText(w, r)
+	if !ok {
 		return
 	}
-	defer r.Body.Close()
 
-	err = h.orderService.CreateOrder(r.Context(), int(userID), string(orderNumber))
+	err := h.orderService.CreateOrder(r.Context(), int(userID), orderNumber)
 	if err != nil {
-		utils.LogError("Failed to upload order: %v", err)
-		switch err {
-		case services.ErrInvalidOrderNumber:
-			utils.SendError(w, http.StatusUnprocessableEntity, "Invalid order number")
-		case services.ErrOrderExistsForOtherUser:
-			utils.SendError(w, http.StatusConflict, "Order already exists for another user")
-		case services.ErrOrderExists:
+		slog.ErrorContext(r.Context(), "Failed to upload order", "error", err)
+		if errors.Is(err, services.ErrOrderExists) {
 			w.WriteHeader(http.StatusOK)
 			return
-		default:
-			utils.SendError(w, http.StatusInternalServerError, "Failed to upload order")
 		}
+		SendServiceError(w, r, err)
 		return
 	}
 
 	utils.SendJSON(w, http.StatusAccepted, map[string]string{"message": "Order uploaded successfully"})
 }
 
+// uploads a batch of orders given as a JSON array or newline-separated text
+func (h *OrderHandler) UploadOrderBatch(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	mediaType, ok := requireContentType(w, r, "application/json", "text/plain")
+	if !ok {
+		return
+	}
+
+	var numbers []string
+	if mediaType == "application/json" {
+		if !decodeJSONBody(w, r, &numbers, maxOrderBatchBodySize) {
+			return
+		}
+		for i := range numbers {
+			numbers[i] = strings.TrimSpace(numbers[i])
+		}
+	} else {
+		text, ok := readTextBody(w, r, maxOrderBatchBodySize)
+		if !ok {
+			return
+		}
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				numbers = append(numbers, line)
+			}
+		}
+	}
+
+	results, err := h.orderService.CreateOrderBatch(r.Context(), int(userID), numbers)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to upload order batch", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendJSON(w, http.StatusOK, results)
+}
+
 // gets a list of user orders
 func (h *OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 	userID, ok := utils.GetUserID(r.Context())
 	if !ok || userID == 0 {
-		utils.SendError(w, http.StatusUnauthorized, "User not authenticated")
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
 		return
 	}
 
 	orders, err := h.orderService.GetUserOrders(r.Context(), int(userID))
 	if err != nil {
-		utils.LogError("Failed to get user orders: %v", err)
-		utils.SendError(w, http.StatusInternalServerError, "Failed to get user orders")
+		slog.ErrorContext(r.Context(), "Failed to get user orders", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Failed to get user orders")
 		return
 	}
 
@@ -84,20 +123,20 @@ func (h *OrderHandler) GetUserOrders(w http.ResponseWriter, r *http.Request) {
 func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	userID, ok := utils.GetUserID(r.Context())
 	if !ok || userID == 0 {
-		utils.SendError(w, http.StatusUnauthorized, "User not authenticated")
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
 		return
 	}
 
-	orderNumber := r.URL.Path[len("/api/orders/"):]
+	orderNumber := r.PathValue("number")
 	if orderNumber == "" {
-		utils.SendError(w, http.StatusBadRequest, "Order number is required")
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidParameter, "Order number is required")
 		return
 	}
 
 	orders, err := h.orderService.GetUserOrders(r.Context(), int(userID))
 	if err != nil {
-		utils.LogError("Failed to get user orders: %v", err)
-		utils.SendError(w, http.StatusInternalServerError, "Failed to get user orders")
+		slog.ErrorContext(r.Context(), "Failed to get user orders", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Failed to get user orders")
 		return
 	}
 
@@ -110,7 +149,7 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if targetOrder == nil {
-		utils.SendError(w, http.StatusNotFound, "Order not found")
+		utils.SendError(w, r, http.StatusNotFound, utils.CodeOrderNotFound, "Order not found")
 		return
 	}
 
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"gophermart/internal/utils"
+)
+
+const (
+	// maximum size of a JSON request body
+	maxJSONBodySize = 64 << 10
+	// maximum size of a plain text request body such as an order number
+	maxTextBodySize = 1 << 10
+)
+
+var errTrailingData = errors.New("body must contain a single JSON value")
+
+// decodes a JSON request body into dst, rejecting other media types, oversized
+// bodies, unknown fields and trailing data; sends a problem and returns false on failure
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if _, ok := requireContentType(w, r, "application/json"); !ok {
+		return false
+	}
+	return decodeJSONBody(w, r, dst, maxJSONBodySize)
+}
+
+// reads a plain text request body with surrounding whitespace trimmed;
+// sends a problem and returns false on failure
+func readText(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if _, ok := requireContentType(w, r, "text/plain"); !ok {
+		return "", false
+	}
+	return readTextBody(w, r, maxTextBodySize)
+}
+
+// decodes a JSON request body of at most maxSize bytes into dst
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst interface{}, maxSize int64) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSize))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil && dec.More() {
+		err = errTrailingData
+	}
+	if err == nil {
+		return true
+	}
+
+	slog.ErrorContext(r.Context(), "Failed to decode request body", "error", err)
+	sendDecodeError(w, r, err)
+	return false
+}
+
+// reads a non-empty plain text request body of at most maxSize bytes
+func readTextBody(w http.ResponseWriter, r *http.Request, maxSize int64) (string, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to read request body", "error", err)
+		sendDecodeError(w, r, err)
+		return "", false
+	}
+
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidBody, "Request body must not be empty")
+		return "", false
+	}
+	return text, true
+}
+
+// checks the request media type is one of the accepted ones, sending 415 otherwise
+func requireContentType(w http.ResponseWriter, r *http.Request, mediaTypes ...string) (string, bool) {
+	actual, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil {
+		for _, mediaType := range mediaTypes {
+			if actual == mediaType {
+				return actual, true
+			}
+		}
+	}
+
+	w.Header().Set("Accept", strings.Join(mediaTypes, ", "))
+	utils.SendError(w, r, http.StatusUnsupportedMediaType, utils.CodeUnsupportedMedia, "Content-Type must be "+strings.Join(mediaTypes, " or "))
+	return "", false
+}
+
+// sends a problem describing why a request body could not be decoded
+func sendDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		utils.SendError(w, r, http.StatusRequestEntityTooLarge, utils.CodeBodyTooLarge,
+			fmt.Sprintf("Request body must not exceed %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, errTrailingData):
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidBody, "Request body must contain a single JSON value")
+	case errors.Is(err, io.EOF):
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidBody, "Request body must not be empty")
+	case errors.As(err, &syntaxErr):
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidBody,
+			fmt.Sprintf("Malformed JSON at offset %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidBody, "Malformed JSON: unexpected end of body")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		p := utils.NewProblem(http.StatusBadRequest, utils.CodeValidationFailed, "Request validation failed")
+		p.Errors = []utils.FieldError{{Field: typeErr.Field, Code: "invalid_type", Message: "must be " + jsonTypeName(typeErr.Type)}}
+		utils.SendProblem(w, r, p)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		p := utils.NewProblem(http.StatusBadRequest, utils.CodeValidationFailed, "Request validation failed")
+		p.Errors = []utils.FieldError{{Field: field, Code: "unknown", Message: "is not allowed"}}
+		utils.SendProblem(w, r, p)
+	default:
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidBody, "Invalid request body: "+err.Error())
+	}
+}
+
+// gets a JSON type name for a Go type
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/models"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// represents a session handler
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+// creates a new session handler
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// gets a list of active user sessions
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	currentID, _ := utils.GetSessionID(r.Context())
+	sessions, err := h.sessionService.List(r.Context(), userID, currentID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get sessions", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Failed to get sessions")
+		return
+	}
+
+	if len(sessions) == 0 {
+		utils.SendJSON(w, http.StatusOK, []models.Session{})
+		return
+	}
+
+	utils.SendJSON(w, http.StatusOK, sessions)
+}
+
+// terminates a user session
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	err := h.sessionService.Revoke(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to revoke session", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
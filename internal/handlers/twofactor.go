@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// header carrying a TOTP code for operations that require a second factor
+const TOTPCodeHeader = "X-TOTP-Code"
+
+// represents a two-factor authentication handler
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+}
+
+// creates a new two-factor authentication handler
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+	}
+}
+
+// represents a two-factor code request
+type TwoFactorCodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// represents a confirmed enrollment response
+type twoFactorConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// starts a TOTP enrollment
+func (h *TwoFactorHandler) Setup(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	enrollment, err := h.twoFactorService.Setup(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to set up two-factor authentication", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendSuccess(w, enrollment)
+}
+
+// confirms a TOTP enrollment
+func (h *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	codes, err := h.twoFactorService.Confirm(r.Context(), userID, req.Code)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to confirm two-factor authentication", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendSuccess(w, twoFactorConfirmResponse{RecoveryCodes: codes})
+}
+
+// disables two-factor authentication
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userID, ok := utils.GetUserID(r.Context())
+	if !ok || userID == 0 {
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "User not authenticated")
+		return
+	}
+
+	var req TwoFactorCodeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := h.twoFactorService.Disable(r.Context(), userID, req.Code, req.RecoveryCode); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to disable two-factor authentication", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	utils.SendSuccess(w, nil)
+}
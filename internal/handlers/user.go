@@ -1,24 +1,29 @@
 package handlers
 
 import (
-	"encoding/json"
+	"log/slog"
 	"net/http"
 
+	"gophermart/internal/models"
 	"gophermart/internal/services"
 	"gophermart/internal/utils"
 )
 
 // represents a user handler
 type UserHandler struct {
-	userService *services.UserService
-	jwtSecret   string
+	userService      *services.UserService
+	twoFactorService *services.TwoFactorService
+	sessionService   *services.SessionService
+	signingKey       *utils.SigningKey
 }
 
 // creates a new user handler
-func NewUserHandler(userService *services.UserService, jwtSecret string) *UserHandler {
+func NewUserHandler(userService *services.UserService, twoFactorService *services.TwoFactorService, sessionService *services.SessionService, signingKey *utils.SigningKey) *UserHandler {
 	return &UserHandler{
-		userService: userService,
-		jwtSecret:   jwtSecret,
+		userService:      userService,
+		twoFactorService: twoFactorService,
+		sessionService:   sessionService,
+		signingKey:       signingKey,
 	}
 }
 
@@ -31,34 +36,23 @@ type RegisterRequest struct {
 // represents a register request
 func (h *UserHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogError("Failed to decode request body: %v", err)
-		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	user, err := h.userService.Register(r.Context(), req.Login, req.Password)
 	if err != nil {
-		utils.LogError("Failed to register user: %v", err)
-		switch err {
-		case services.ErrUserExists:
-			utils.SendError(w, http.StatusConflict, "User already exists")
-		case services.ErrInvalidPassword:
-			utils.SendError(w, http.StatusBadRequest, "Invalid password")
-		default:
-			utils.SendError(w, http.StatusInternalServerError, "Internal server error")
-		}
+		slog.ErrorContext(r.Context(), "Failed to register user", "error", err)
+		SendServiceError(w, r, err)
 		return
 	}
 
-	token, err := utils.GenerateToken(user.ID, h.jwtSecret)
-	if err != nil {
-		utils.LogError("Failed to generate token: %v", err)
-		utils.SendError(w, http.StatusInternalServerError, "Internal server error")
+	if err := h.startSession(w, r, user); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to start session", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
 		return
 	}
 
-	w.Header().Set("Authorization", "Bearer "+token)
 	utils.SendSuccess(w, nil)
 }
 
@@ -71,32 +65,105 @@ type LoginRequest struct {
 // represents a login request
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		utils.LogError("Failed to decode request body: %v", err)
-		utils.SendError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
 	user, err := h.userService.Authenticate(r.Context(), req.Login, req.Password)
 	if err != nil {
-		utils.LogError("Failed to authenticate user: %v", err)
-		switch err {
-		case services.ErrUserNotFound, services.ErrInvalidPassword:
-			utils.SendError(w, http.StatusUnauthorized, "Invalid credentials")
-		default:
-			utils.SendError(w, http.StatusInternalServerError, "Internal server error")
+		slog.ErrorContext(r.Context(), "Failed to authenticate user", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	// users with 2FA enabled get a short-lived token for the second step instead
+	if user.TOTPEnabled {
+		mfaToken, err := utils.GenerateMFAToken(user.ID, h.signingKey)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to generate MFA token", "error", err)
+			utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
+			return
 		}
+
+		utils.SendSuccess(w, twoFactorChallenge{
+			TwoFactorRequired: true,
+			MFAToken:          mfaToken,
+		})
+		return
+	}
+
+	// create session and JWT token
+	if err := h.startSession(w, r, user); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to start session", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
+		return
+	}
+
+	utils.SendSuccess(w, nil)
+}
+
+// represents a second login step challenge
+type twoFactorChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	MFAToken          string `json:"mfa_token"`
+}
+
+// represents a second login step request
+type LoginTwoFactorRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// completes a login for users with two-factor authentication enabled
+func (h *UserHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req LoginTwoFactorRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	// create JWT token
-	token, err := utils.GenerateToken(user.ID, h.jwtSecret)
+	claims, err := utils.ParseToken(req.MFAToken, h.signingKey)
+	if err != nil || claims.Purpose != utils.TokenPurposeMFA {
+		slog.ErrorContext(r.Context(), "Failed to parse MFA token", "error", err)
+		utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthInvalidToken, "Invalid token")
+		return
+	}
+
+	err = h.twoFactorService.Verify(r.Context(), claims.UserID, req.Code, req.RecoveryCode)
 	if err != nil {
-		utils.LogError("Failed to generate token: %v", err)
-		utils.SendError(w, http.StatusInternalServerError, "Internal server error")
+		slog.ErrorContext(r.Context(), "Failed to verify two-factor code", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	user, err := h.userService.GetByID(r.Context(), claims.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to get user", "error", err)
+		SendServiceError(w, r, err)
+		return
+	}
+
+	if err := h.startSession(w, r, user); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to start session", "error", err)
+		utils.SendError(w, r, http.StatusInternalServerError, utils.CodeInternal, "Internal server error")
 		return
 	}
 
-	w.Header().Set("Authorization", "Bearer "+token)
 	utils.SendSuccess(w, nil)
 }
+
+// creates a session and sets an access token for it in the response header
+func (h *UserHandler) startSession(w http.ResponseWriter, r *http.Request, user *models.User) error {
+	session, err := h.sessionService.Create(r.Context(), user.ID, r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateToken(user.ID, session.ID, user.Roles, h.signingKey, h.sessionService.TTL())
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Authorization", "Bearer "+token)
+	return nil
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gophermart"
+
+var (
+	// served HTTP requests by route and status
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of served HTTP requests.",
+	}, []string{"method", "route", "status"})
+
+	// HTTP request latency by route
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of served HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// accrual system polls by response status code, or error when there was no response
+	AccrualRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "accrual_requests_total",
+		Help:      "Number of accrual system polls by response status.",
+	}, []string{"status"})
+
+	// accrual system poll latency
+	AccrualRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "accrual_request_duration_seconds",
+		Help:      "Latency of accrual system polls.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// orders waiting for a final accrual status
+	PendingOrders = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pending_orders",
+		Help:      "Number of orders waiting for a final accrual status at the last sweep.",
+	})
+
+	// points credited for processed orders
+	PointsCredited = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_credited_total",
+		Help:      "Points credited to users for processed orders.",
+	})
+
+	// points withdrawn by users
+	PointsWithdrawn = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "points_withdrawn_total",
+		Help:      "Points withdrawn by users.",
+	})
+
+	// password hashing time by operation and algorithm
+	PasswordHashDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "password_hash_duration_seconds",
+		Help:      "Time spent hashing and verifying passwords.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"operation", "algorithm"})
+)
+
+// returns a handler serving the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gophermart/internal/utils"
+)
+
+// logs every served request with its route, status, latency and user
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry, ok := utils.GetAccessLogEntry(r.Context())
+		if !ok {
+			entry = &utils.AccessLogEntry{}
+			r = r.WithContext(utils.WithAccessLogEntry(r.Context(), entry))
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", entry.Route),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int("size", rec.size),
+		}
+		if entry.UserID != 0 {
+			attrs = append(attrs, slog.Int64("user_id", entry.UserID))
+		}
+		slog.LogAttrs(r.Context(), level, "Request served", attrs...)
+	})
+}
+
+// represents a response writer remembering the status and body size
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// records the status
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// counts written bytes
+func (w *statusRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += n
+	return n, err
+}
+
+// flushes streamed responses
+func (w *statusRecorder) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// returns the wrapped response writer
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
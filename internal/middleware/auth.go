@@ -1,21 +1,36 @@
 package middleware
 
 import (
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"gophermart/internal/handlers"
+	"gophermart/internal/models"
+	"gophermart/internal/services"
 	"gophermart/internal/utils"
 )
 
+const (
+	// header carrying a partner API key
+	APIKeyHeader = "X-API-Key"
+	// header naming the login of the user a partner acts on behalf of
+	OnBehalfOfHeader = "X-On-Behalf-Of"
+)
+
 // represents an auth middleware
 type AuthMiddleware struct {
-	jwtSecret string
+	signingKey     *utils.SigningKey
+	apiKeyService  *services.APIKeyService
+	sessionService *services.SessionService
 }
 
 // creates a new auth middleware
-func NewAuthMiddleware(jwtSecret string) *AuthMiddleware {
+func NewAuthMiddleware(signingKey *utils.SigningKey, apiKeyService *services.APIKeyService, sessionService *services.SessionService) *AuthMiddleware {
 	return &AuthMiddleware{
-		jwtSecret: jwtSecret,
+		signingKey:     signingKey,
+		apiKeyService:  apiKeyService,
+		sessionService: sessionService,
 	}
 }
 
@@ -25,24 +40,77 @@ func (m *AuthMiddleware) Auth(next http.Handler) http.Handler {
 
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			utils.SendError(w, http.StatusUnauthorized, "Authorization header is required")
+			utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthRequired, "Authorization header is required")
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			utils.SendError(w, http.StatusUnauthorized, "Invalid authorization header format")
+			utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthInvalidHeader, "Invalid authorization header format")
+			return
+		}
+
+		claims, err := utils.ParseToken(parts[1], m.signingKey)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to parse token", "error", err)
+			utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthInvalidToken, "Invalid token")
+			return
+		}
+
+		// tokens issued for a pending two-factor login are not access tokens,
+		// and every access token must belong to a server-tracked session
+		if claims.Purpose != "" || claims.SessionID == "" {
+			utils.SendError(w, r, http.StatusUnauthorized, utils.CodeAuthInvalidToken, "Invalid token")
 			return
 		}
 
-		claims, err := utils.ParseToken(parts[1], m.jwtSecret)
+		err = m.sessionService.Validate(r.Context(), claims.UserID, claims.SessionID, utils.ClientIP(r))
 		if err != nil {
-			utils.LogError("Failed to parse token: %v", err)
-			utils.SendError(w, http.StatusUnauthorized, "Invalid token")
+			slog.ErrorContext(r.Context(), "Failed to validate session", "error", err)
+			handlers.SendServiceError(w, r, err)
 			return
 		}
 
 		ctx := utils.WithUserID(r.Context(), claims.UserID)
+		ctx = utils.WithRoles(ctx, claims.Roles)
+		ctx = utils.WithSessionID(ctx, claims.SessionID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticates a user by JWT or a partner API key granted the scope
+func (m *AuthMiddleware) AuthScoped(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m.authScoped(scope, next)
+	}
+}
+
+// authenticates a user by JWT or a partner API key granted the scope
+func (m *AuthMiddleware) authScoped(scope string, next http.Handler) http.Handler {
+	jwtAuth := m.Auth(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey := r.Header.Get(APIKeyHeader)
+		if apiKey == "" {
+			jwtAuth.ServeHTTP(w, r)
+			return
+		}
+
+		key, user, err := m.apiKeyService.Authenticate(r.Context(), apiKey, r.Header.Get(OnBehalfOfHeader), utils.ClientIP(r))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to authenticate api key", "error", err)
+			handlers.SendServiceError(w, r, err)
+			return
+		}
+
+		if !services.HasScope(key, scope) {
+			utils.SendError(w, r, http.StatusForbidden, utils.CodeAPIKeyScopeMissing, "API key lacks scope "+scope)
+			return
+		}
+
+		ctx := utils.WithUserID(r.Context(), user.ID)
+		ctx = utils.WithRoles(ctx, []string{models.RoleUser})
+		ctx = utils.WithAPIKeyID(ctx, key.ID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,253 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gophermart/internal/utils"
+)
+
+const (
+	// default cap on a decompressed request body, protects against zip bombs
+	DefaultMaxDecompressedSize = 10 << 20
+	// default response size below which compression is not worth it
+	DefaultGzipMinSize = 1024
+)
+
+// represents a gzip request decompression and response compression middleware
+type GzipMiddleware struct {
+	maxDecompressedSize int64
+	minSize             int
+	writers             sync.Pool
+}
+
+// creates a new gzip middleware
+func NewGzipMiddleware(maxDecompressedSize int64, minSize int) *GzipMiddleware {
+	return &GzipMiddleware{
+		maxDecompressedSize: maxDecompressedSize,
+		minSize:             minSize,
+		writers: sync.Pool{
+			New: func() interface{} {
+				gz, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+				return gz
+			},
+		},
+	}
+}
+
+// transparently decompresses gzip-encoded request bodies
+func (m *GzipMiddleware) Decompress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := strings.TrimSpace(strings.ToLower(r.Header.Get("Content-Encoding")))
+		switch encoding {
+		case "", "identity":
+			next.ServeHTTP(w, r)
+			return
+		case "gzip", "x-gzip":
+		default:
+			utils.SendError(w, r, http.StatusUnsupportedMediaType, utils.CodeUnsupportedEncoding, "Unsupported content encoding")
+			return
+		}
+
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create gzip reader", "error", err)
+			utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidBody, "Invalid gzip body")
+			return
+		}
+		defer gz.Close()
+
+		// reading past the cap fails with *http.MaxBytesError
+		r.Body = http.MaxBytesReader(w, gz, m.maxDecompressedSize)
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// compresses responses for clients that accept gzip
+func (m *GzipMiddleware) Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, m: m}
+		defer gw.close()
+
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// checks if an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		q := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		return q > 0
+	}
+	return false
+}
+
+// checks if a content type is worth compressing
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case mediaType == "text/event-stream":
+		// streams are flushed event by event
+		return false
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		return true
+	case mediaType == "application/javascript", mediaType == "application/xml":
+		return true
+	}
+	return false
+}
+
+// represents a response writer that buffers the start of a body to decide on compression
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	m           *GzipMiddleware
+	status      int
+	wroteHeader bool
+	decided     bool
+	gz          *gzip.Writer
+	buf         []byte
+}
+
+// records the status code until compression is decided
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	if status < http.StatusOK {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = status
+
+	if status == http.StatusNoContent || status == http.StatusNotModified || w.Header().Get("Content-Encoding") != "" {
+		w.decide(false)
+	}
+}
+
+// buffers body bytes until the size threshold is reached
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.m.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// sends buffered data, deciding on compression first if needed
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.decide(len(w.buf) >= w.m.minSize)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// returns the wrapped response writer
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writes headers and buffered data, compressing them if allowed
+func (w *gzipResponseWriter) decide(allow bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if allow && h.Get("Content-Encoding") == "" && isCompressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = w.m.writers.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.gz != nil {
+		_, err := w.gz.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// finishes the response
+func (w *gzipResponseWriter) close() {
+	if !w.wroteHeader {
+		return
+	}
+	if !w.decided {
+		w.decide(false)
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			slog.Error("Failed to close gzip writer", "error", err)
+		}
+		w.gz.Reset(io.Discard)
+		w.m.writers.Put(w.gz)
+		w.gz = nil
+	}
+}
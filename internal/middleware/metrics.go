@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gophermart/internal/metrics"
+	"gophermart/internal/utils"
+)
+
+// route label of requests matching no route, keeping label values bounded
+const unmatchedRoute = "unmatched"
+
+// counts requests and observes their latency by route and status
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry, ok := utils.GetAccessLogEntry(r.Context())
+		if !ok {
+			entry = &utils.AccessLogEntry{}
+			r = r.WithContext(utils.WithAccessLogEntry(r.Context(), entry))
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := entry.Route
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gophermart/internal/ratelimit"
+	"gophermart/internal/utils"
+)
+
+// represents a rate limiting middleware
+type RateLimiter struct {
+	store ratelimit.Store
+	// policies by name, replaced as a whole when the config is reloaded
+	policies atomic.Pointer[map[string]ratelimit.Policy]
+}
+
+// creates a new rate limiting middleware enforcing the policies
+func NewRateLimiter(store ratelimit.Store, policies []ratelimit.Policy) *RateLimiter {
+	m := &RateLimiter{store: store}
+	m.SetPolicies(policies)
+	return m
+}
+
+// replaces the policies; buckets keep their tokens and are refilled at the new rate
+func (m *RateLimiter) SetPolicies(policies []ratelimit.Policy) {
+	byName := make(map[string]ratelimit.Policy, len(policies))
+	for _, policy := range policies {
+		byName[policy.Name] = policy
+	}
+	m.policies.Store(&byName)
+}
+
+// limits requests by the named policy, per user when authenticated and per client IP otherwise;
+// requests are not limited while no policy has the name
+func (m *RateLimiter) Limit(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			policy, ok := (*m.policies.Load())[name]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			result, err := m.store.TakeRateLimitToken(r.Context(), rateLimitKey(r, policy), policy)
+			if err != nil {
+				// an unavailable store must not take the service down
+				slog.ErrorContext(r.Context(), "Failed to take rate limit token", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Window)))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+				utils.SendError(w, r, http.StatusTooManyRequests, utils.CodeRateLimited, "Too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// builds a bucket key from the policy and the user ID or client IP
+func rateLimitKey(r *http.Request, policy ratelimit.Policy) string {
+	if userID, ok := utils.GetUserID(r.Context()); ok && userID != 0 {
+		return fmt.Sprintf("%s:user:%d", policy.Name, userID)
+	}
+	return fmt.Sprintf("%s:ip:%s", policy.Name, utils.ClientIP(r))
+}
+
+// rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
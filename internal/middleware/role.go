@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gophermart/internal/utils"
+)
+
+// allows a request only if the authenticated user has any of the roles
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !utils.HasAnyRole(r.Context(), roles...) {
+				utils.SendError(w, r, http.StatusForbidden, utils.CodeAuthForbidden, "Insufficient permissions")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
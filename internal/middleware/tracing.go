@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"gophermart/internal/tracing"
+	"gophermart/internal/utils"
+)
+
+var tracer = tracing.Tracer("gophermart/internal/middleware")
+
+// starts a server span for every request, continuing the trace sent by the client
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(utils.ClientIP(r)),
+			),
+		)
+		defer span.End()
+
+		entry, ok := utils.GetAccessLogEntry(ctx)
+		if !ok {
+			entry = &utils.AccessLogEntry{}
+			ctx = utils.WithAccessLogEntry(ctx, entry)
+		}
+		if requestID, ok := utils.GetRequestID(ctx); ok {
+			span.SetAttributes(attribute.String("request.id", requestID))
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if entry.Route != "" {
+			span.SetName(r.Method + " " + entry.Route)
+			span.SetAttributes(semconv.HTTPRoute(entry.Route))
+		}
+		if entry.UserID != 0 {
+			span.SetAttributes(attribute.Int64("enduser.id", entry.UserID))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
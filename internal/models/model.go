@@ -1,15 +1,61 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
+// user roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// prefix of the login a deleted account is anonymized to, reserved on registration
+const DeletedLoginPrefix = "deleted-"
+
+// API key scopes
+const (
+	ScopeOrdersWrite     = "orders:write"
+	ScopeBalanceWithdraw = "balance:withdraw"
+	ScopeUsersRead       = "users:read"
+)
+
+// order statuses
+const (
+	OrderStatusNew        = "NEW"
+	OrderStatusProcessing = "PROCESSING"
+	OrderStatusInvalid    = "INVALID"
+	OrderStatusProcessed  = "PROCESSED"
+)
+
 // represents a user
 type User struct {
-	ID           int64     `json:"-"`
-	Login        string    `json:"login"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"-"`
+	ID           int64  `json:"-"`
+	Login        string `json:"login"`
+	PasswordHash string `json:"-"`
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `json:"-"`
+	// two-factor checks are refused until then after too many wrong codes
+	TOTPLockedUntil *time.Time `json:"-"`
+	Roles           []string   `json:"-"`
+	CreatedAt       time.Time  `json:"-"`
+}
+
+// represents a user as seen by administrators
+type UserInfo struct {
+	ID               int64       `json:"id"`
+	Login            string      `json:"login"`
+	Roles            []string    `json:"roles"`
+	TwoFactorEnabled bool        `json:"two_factor_enabled"`
+	CreatedAt        time.Time   `json:"created_at"`
+	Balance          UserBalance `json:"balance"`
+}
+
+// represents a pending two-factor enrollment
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
 }
 
 // represents a user balance
@@ -26,9 +72,172 @@ type Order struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// order event types
+const (
+	OrderEventStatus  = "order.status"
+	OrderEventAccrual = "balance.credited"
+)
+
+// represents a change of an order made by the accrual worker
+type OrderEvent struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"-"`
+	Type      string    `json:"type"`
+	Order     string    `json:"order"`
+	Status    string    `json:"status,omitempty"`
+	Amount    float32   `json:"amount,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// outcomes of a single order in a batch upload
+const (
+	OrderBatchAccepted        = "accepted"
+	OrderBatchAlreadyUploaded = "already_uploaded"
+	OrderBatchOwnedByOther    = "owned_by_other_user"
+	OrderBatchInvalid         = "invalid"
+)
+
+// represents the outcome of a single order in a batch upload
+type OrderBatchResult struct {
+	Number string `json:"number"`
+	Status string `json:"status"`
+}
+
 // represents a withdrawal
 type Withdrawal struct {
 	Order     string    `json:"order"`
 	Sum       float32   `json:"sum"`
 	CreatedAt time.Time `json:"processed_at"`
 }
+
+// represents a partner API key
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  *int64     `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP *string    `json:"last_used_ip,omitempty"`
+	UsageCount int64      `json:"usage_count"`
+}
+
+// represents a newly created API key with its secret, shown only once
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// represents a login session
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"-"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current"`
+}
+
+// balance history entry types
+const (
+	BalanceEntryAccrual    = "accrual"
+	BalanceEntryWithdrawal = "withdrawal"
+	BalanceEntryAdjustment = "adjustment"
+)
+
+// represents a single change of a user balance
+type BalanceEntry struct {
+	Type      string    `json:"type"`
+	Amount    float32   `json:"amount"`
+	Order     string    `json:"order,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// represents a user profile in a personal data export
+type UserProfile struct {
+	Login            string    `json:"login"`
+	Roles            []string  `json:"roles"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// represents a personal data export
+type UserExport struct {
+	Profile        UserProfile    `json:"profile"`
+	Balance        UserBalance    `json:"balance"`
+	Orders         []Order        `json:"orders"`
+	Withdrawals    []Withdrawal   `json:"withdrawals"`
+	BalanceHistory []BalanceEntry `json:"balance_history"`
+	Sessions       []Session      `json:"sessions"`
+	ExportedAt     time.Time      `json:"exported_at"`
+}
+
+// health check statuses
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+	HealthStatusFailed   = "failed"
+)
+
+// represents the result of a single health check
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// represents a health report with the result of every check
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// audit event types
+const (
+	AuditUserRegistered   = "user.registered"
+	AuditLoginSucceeded   = "auth.login_succeeded"
+	AuditLoginFailed      = "auth.login_failed"
+	AuditBalanceWithdrawn = "balance.withdrawn"
+	AuditBalanceCredited  = "balance.credited"
+	AuditBalanceAdjusted  = "balance.adjusted"
+	AuditOrderOverridden  = "order.status_overridden"
+	AuditUserDeleted      = "user.deleted"
+)
+
+// represents an entry of the audit log; each entry hashes its content together
+// with the hash of the previous one, so that changed or removed entries break the chain
+type AuditEvent struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	ActorID   *int64          `json:"actor_id"`
+	APIKeyID  *int64          `json:"api_key_id"`
+	SubjectID *int64          `json:"subject_id"`
+	IP        string          `json:"ip"`
+	RequestID string          `json:"request_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+// represents audit log search criteria
+type AuditFilter struct {
+	Type    string
+	UserID  int64
+	AfterID int64
+	Limit   int
+}
+
+// represents the result of an audit chain verification
+type AuditVerification struct {
+	Valid          bool   `json:"valid"`
+	Checked        int64  `json:"checked"`
+	FirstInvalidID *int64 `json:"first_invalid_id"`
+	Error          string `json:"error,omitempty"`
+}
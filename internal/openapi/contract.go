@@ -0,0 +1,185 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// checks that a Go type encodes to the shape of a named component schema
+func (d *Document) CheckType(schemaName string, t reflect.Type) error {
+	s, ok := d.Components.Schemas[schemaName]
+	if !ok {
+		return fmt.Errorf("unknown schema %s", schemaName)
+	}
+
+	var problems []string
+	d.checkType(s, t, schemaName, &problems)
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// compares a schema with a Go type at a field path, collecting differences
+func (d *Document) checkType(s *Schema, t reflect.Type, field string, problems *[]string) {
+	s, err := d.resolve(s)
+	if err != nil {
+		*problems = append(*problems, field+": "+err.Error())
+		return
+	}
+
+	fail := func(format string, args ...interface{}) {
+		*problems = append(*problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if t.Kind() == reflect.Pointer {
+		if !s.Nullable {
+			fail("pointer field must be nullable in the schema")
+		}
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		if s.Type != "string" {
+			fail("time is encoded as a string, schema has %s", s.Type)
+		}
+		return
+	}
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		// custom encodings are checked by the validator
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if s.Type != "object" {
+			fail("struct is encoded as an object, schema has %s", s.Type)
+			return
+		}
+		fields := jsonFields(t)
+		required := make(map[string]bool, len(s.Required))
+		for _, name := range s.Required {
+			required[name] = true
+		}
+
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			f := fields[name]
+			prop, ok := s.Properties[name]
+			if !ok {
+				*problems = append(*problems, join(field, name)+": field is missing from the schema")
+				continue
+			}
+			if required[name] && f.omitEmpty {
+				*problems = append(*problems, join(field, name)+": required property is omitted when empty")
+			}
+			d.checkType(prop, f.typ, join(field, name), problems)
+		}
+
+		props := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			props = append(props, name)
+		}
+		sort.Strings(props)
+		for _, name := range props {
+			if _, ok := fields[name]; !ok {
+				*problems = append(*problems, join(field, name)+": property is missing from the type")
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if s.Type != "string" {
+				fail("bytes are encoded as a string, schema has %s", s.Type)
+			}
+			return
+		}
+		if s.Type != "array" {
+			fail("slice is encoded as an array, schema has %s", s.Type)
+			return
+		}
+		if s.Items != nil {
+			d.checkType(s.Items, t.Elem(), field+"[]", problems)
+		}
+	case reflect.Map:
+		if s.Type != "object" {
+			fail("map is encoded as an object, schema has %s", s.Type)
+		}
+	case reflect.String:
+		if s.Type != "string" {
+			fail("string field, schema has %s", s.Type)
+		}
+	case reflect.Bool:
+		if s.Type != "boolean" {
+			fail("bool field, schema has %s", s.Type)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s.Type != "integer" {
+			fail("integer field, schema has %s", s.Type)
+		}
+	case reflect.Float32, reflect.Float64:
+		if s.Type != "number" {
+			fail("float field, schema has %s", s.Type)
+		}
+	case reflect.Interface:
+		// any value is allowed
+	default:
+		fail("unsupported kind %s", t.Kind())
+	}
+}
+
+// represents a struct field as seen by encoding/json
+type jsonField struct {
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// lists the encoded fields of a struct, flattening embedded structs
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			et := f.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				for n, ef := range jsonFields(et) {
+					if _, ok := fields[n]; !ok {
+						fields[n] = ef
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = jsonField{typ: f.Type, omitEmpty: strings.Contains(","+opts+",", ",omitempty,")}
+	}
+	return fields
+}
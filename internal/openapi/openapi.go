@@ -0,0 +1,133 @@
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// represents the parts of an OpenAPI 3 document used for validation
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+// represents an API operation
+type Operation struct {
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// represents an operation request body
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// represents an operation response
+type Response struct {
+	Content map[string]*MediaType `json:"content"`
+}
+
+// represents a body of a single media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// parses the embedded API document
+func Load() (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(document, doc); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi document: %w", err)
+	}
+	return doc, nil
+}
+
+// serves the API document
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(document)
+}
+
+// finds the operation of a request, preferring literal path segments over parameters
+func (d *Document) FindOperation(method, path string) (*Operation, bool) {
+	segments := splitPath(path)
+	method = strings.ToLower(method)
+
+	var best *Operation
+	var bestPattern []string
+	for pattern, item := range d.Paths {
+		op, ok := item[method]
+		if !ok {
+			continue
+		}
+		patternSegments := splitPath(pattern)
+		if !matches(patternSegments, segments) {
+			continue
+		}
+		if best == nil || moreSpecific(patternSegments, bestPattern) {
+			best, bestPattern = op, patternSegments
+		}
+	}
+	return best, best != nil
+}
+
+// resolves a schema reference
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	for s != nil && s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		resolved, ok := d.Components.Schemas[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown schema %s", s.Ref)
+		}
+		s = resolved
+	}
+	return s, nil
+}
+
+// splits a path into segments
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// checks if pattern segments match path segments
+func matches(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, segment := range pattern {
+		if isParam(segment) {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reports whether a pattern has a literal segment where the other has a parameter first
+func moreSpecific(pattern, other []string) bool {
+	for i := range pattern {
+		if isParam(pattern[i]) != isParam(other[i]) {
+			return !isParam(pattern[i])
+		}
+	}
+	return false
+}
+
+// checks if a pattern segment is a {name} parameter
+func isParam(segment string) bool {
+	return len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}'
+}
@@ -0,0 +1,140 @@
+package openapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gophermart/internal/handlers"
+	"gophermart/internal/middleware"
+	"gophermart/internal/models"
+	"gophermart/internal/openapi"
+	"gophermart/internal/router"
+	"gophermart/internal/services"
+	"gophermart/internal/utils"
+)
+
+// maps component schemas to the types handlers encode for them
+var contracts = []struct {
+	schema string
+	value  interface{}
+}{
+	{"Order", models.Order{}},
+	{"OrderBatchResult", models.OrderBatchResult{}},
+	{"OrderEvent", models.OrderEvent{}},
+	{"Balance", models.UserBalance{}},
+	{"BalanceEntry", models.BalanceEntry{}},
+	{"Withdrawal", models.Withdrawal{}},
+	{"Session", models.Session{}},
+	{"UserInfo", models.UserInfo{}},
+	{"UserProfile", models.UserProfile{}},
+	{"UserExport", models.UserExport{}},
+	{"APIKey", models.APIKey{}},
+	{"CreatedAPIKey", models.CreatedAPIKey{}},
+	{"TOTPEnrollment", models.TOTPEnrollment{}},
+	{"HealthReport", models.HealthReport{}},
+	{"HealthCheck", models.HealthCheck{}},
+	{"AuditEvent", models.AuditEvent{}},
+	{"AuditVerification", models.AuditVerification{}},
+	{"Problem", utils.Problem{}},
+	{"FieldError", utils.FieldError{}},
+}
+
+func TestResponseTypesMatchDocument(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	for _, c := range contracts {
+		if err := doc.CheckType(c.schema, reflect.TypeOf(c.value)); err != nil {
+			t.Errorf("%s drifted from the document: %v", c.schema, err)
+		}
+	}
+}
+
+// builds the application routes whose responses do not need a database
+func newRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	validator := openapi.NewValidator(doc, func(r *http.Request, err error) {
+		t.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	})
+
+	hasher, err := utils.NewArgon2idHasher(utils.DefaultArgon2Params())
+	if err != nil {
+		t.Fatalf("NewArgon2idHasher() error = %v", err)
+	}
+	signingKey := utils.NewSigningKey(strings.Repeat("s", 32), 0)
+
+	userService := services.NewUserService(nil, hasher, nil)
+	twoFactorService := services.NewTwoFactorService(nil)
+	sessionService := services.NewSessionService(nil, time.Hour)
+	apiKeyService := services.NewAPIKeyService(nil)
+
+	userHandler := handlers.NewUserHandler(userService, twoFactorService, sessionService, signingKey)
+	orderHandler := handlers.NewOrderHandler(nil)
+	balanceHandler := handlers.NewBalanceHandler(nil)
+	auditHandler := handlers.NewAuditHandler(nil)
+	authMiddleware := middleware.NewAuthMiddleware(signingKey, apiKeyService, sessionService)
+
+	r := router.New()
+	r.Use(validator.Validate)
+	r.Get("/api/openapi.json", openapi.Handler)
+	r.Post("/api/user/register", userHandler.Register)
+
+	api := r.Group("/api")
+	api.With(authMiddleware.AuthScoped(models.ScopeOrdersWrite)).Post("/user/orders", orderHandler.UploadOrder)
+	api.With(authMiddleware.AuthScoped(models.ScopeUsersRead)).Get("/user/orders", orderHandler.GetUserOrders)
+	api.With(authMiddleware.AuthScoped(models.ScopeUsersRead)).Get("/user/balance", balanceHandler.GetBalance)
+
+	admin := r.Group("/api/admin", authMiddleware.Auth, middleware.RequireRole(models.RoleAdmin))
+	admin.Get("/audit", auditHandler.List)
+	return r
+}
+
+func TestHandlersMatchDocument(t *testing.T) {
+	r := newRouter(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		header      string
+		want        int
+	}{
+		{name: "document", method: http.MethodGet, path: "/api/openapi.json", want: http.StatusOK},
+		{name: "register validation", method: http.MethodPost, path: "/api/user/register", contentType: "application/json", body: `{"login":"a","password":"b"}`, want: http.StatusBadRequest},
+		{name: "upload without token", method: http.MethodPost, path: "/api/user/orders", contentType: "text/plain", body: "12345678903", want: http.StatusUnauthorized},
+		{name: "orders without token", method: http.MethodGet, path: "/api/user/orders", want: http.StatusUnauthorized},
+		{name: "balance with malformed header", method: http.MethodGet, path: "/api/user/balance", header: "Token abc", want: http.StatusUnauthorized},
+		{name: "audit with invalid token", method: http.MethodGet, path: "/api/admin/audit", header: "Bearer abc", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gophermart/internal/utils"
+)
+
+// represents the JSON Schema subset used by the API document
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []interface{}      `json:"enum"`
+	Nullable             bool               `json:"nullable"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+}
+
+// validates a decoded JSON value against a schema, returning every violation
+func (d *Document) Validate(s *Schema, value interface{}) []utils.FieldError {
+	var violations []utils.FieldError
+	d.validate(s, value, "", &violations)
+	return violations
+}
+
+// validates a value at a field path, collecting violations
+func (d *Document) validate(s *Schema, value interface{}, field string, violations *[]utils.FieldError) {
+	s, err := d.resolve(s)
+	if err != nil {
+		*violations = append(*violations, utils.FieldError{Field: field, Code: "invalid_schema", Message: err.Error()})
+		return
+	}
+	if s == nil {
+		return
+	}
+
+	fail := func(code, message string) {
+		*violations = append(*violations, utils.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if value == nil {
+		if !s.Nullable && s.Type != "" {
+			fail("invalid_type", "must be "+article(s.Type))
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			fail("invalid_type", "must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				*violations = append(*violations, utils.FieldError{Field: join(field, name), Code: "required", Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*violations = append(*violations, utils.FieldError{Field: join(field, name), Code: "unknown", Message: "is not allowed"})
+				}
+				continue
+			}
+			d.validate(prop, obj[name], join(field, name), violations)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("invalid_type", "must be an array")
+			return
+		}
+		for i, item := range items {
+			d.validate(s.Items, item, fmt.Sprintf("%s[%d]", field, i), violations)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			fail("invalid_type", "must be a string")
+			return
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail("invalid_type", "must be a number")
+			return
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			fail("invalid_type", "must be an integer")
+			return
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("invalid_type", "must be a boolean")
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 && !contains(s.Enum, value) {
+		fail("invalid_value", fmt.Sprintf("must be one of %v", s.Enum))
+	}
+}
+
+// joins a field path and a property name
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+// checks if an enum contains a value
+func contains(enum []interface{}, value interface{}) bool {
+	for _, v := range enum {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixes a type name with an article
+func article(typ string) string {
+	switch typ {
+	case "object", "array", "integer":
+		return "an " + typ
+	}
+	return "a " + typ
+}
@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gophermart/internal/utils"
+)
+
+// maximum size of a request body read for validation
+const maxValidatedBodySize = 10 << 20
+
+// represents a middleware checking requests and responses against the API document
+type Validator struct {
+	doc *Document
+	// receives responses that break the document
+	report func(r *http.Request, err error)
+}
+
+// creates a new validator reporting response violations to report
+func NewValidator(doc *Document, report func(r *http.Request, err error)) *Validator {
+	return &Validator{doc: doc, report: report}
+}
+
+// rejects requests that break the document and reports responses that do
+func (v *Validator) Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, ok := v.doc.FindOperation(r.Method, r.URL.Path)
+		if !ok {
+			// unknown routes are answered by the router
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if op.RequestBody != nil && !v.validateRequest(w, r, op.RequestBody) {
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if err := v.validateResponse(op, rec); err != nil {
+			v.report(r, err)
+		}
+	})
+}
+
+// checks the request media type and JSON body, sending a problem on failure
+func (v *Validator) validateRequest(w http.ResponseWriter, r *http.Request, body *RequestBody) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	media, ok := body.Content[mediaType]
+	if err != nil || !ok {
+		if !body.Required && r.ContentLength == 0 {
+			return true
+		}
+		utils.SendError(w, r, http.StatusUnsupportedMediaType, utils.CodeUnsupportedMedia, "Content-Type must be one of "+strings.Join(mediaTypes(body.Content), ", "))
+		return false
+	}
+	if !isJSON(mediaType) {
+		return true
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBodySize))
+	if err != nil {
+		utils.SendError(w, r, http.StatusBadRequest, utils.CodeInvalidBody, "Failed to read request body")
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		// malformed bodies are described by the handlers
+		return true
+	}
+
+	if violations := v.doc.Validate(media.Schema, value); len(violations) > 0 {
+		p := utils.NewProblem(http.StatusBadRequest, utils.CodeValidationFailed, "Request validation failed")
+		p.Errors = violations
+		utils.SendProblem(w, r, p)
+		return false
+	}
+	return true
+}
+
+// checks that a recorded response is declared by the operation
+func (v *Validator) validateResponse(op *Operation, rec *responseRecorder) error {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not declared", status)
+	}
+
+	if len(resp.Content) == 0 {
+		if rec.size > 0 {
+			return fmt.Errorf("status %d must not have a body", status)
+		}
+		return nil
+	}
+	if rec.size == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("status %d content type %q is not declared", status, mediaType)
+	}
+	if !rec.capture {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(rec.body.Bytes(), &value); err != nil {
+		return fmt.Errorf("status %d body is not valid JSON: %w", status, err)
+	}
+	if violations := v.doc.Validate(media.Schema, value); len(violations) > 0 {
+		messages := make([]string, 0, len(violations))
+		for _, f := range violations {
+			messages = append(messages, f.Field+": "+f.Message)
+		}
+		return fmt.Errorf("status %d body does not match the schema: %s", status, strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// represents a response writer keeping a copy of JSON bodies
+type responseRecorder struct {
+	http.ResponseWriter
+	status  int
+	size    int
+	capture bool
+	body    bytes.Buffer
+}
+
+// records the status and decides whether the body is worth keeping
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		w.capture = isJSON(mediaType)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// writes the body, keeping a copy of JSON
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.size += len(p)
+	if w.capture {
+		w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// flushes streamed responses
+func (w *responseRecorder) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// returns the wrapped response writer
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// checks if a media type carries JSON
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// lists the media types of a content map
+func mediaTypes(content map[string]*MediaType) []string {
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// how often idle buckets are dropped
+const SweepInterval = time.Minute
+
+// represents a token bucket kept in memory
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	window    time.Duration
+}
+
+// represents a token bucket storage local to the process
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// creates a new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// takes a token from the bucket of a key
+func (s *MemoryStore) TakeRateLimitToken(ctx context.Context, key string, policy Policy) (Result, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Limit), updatedAt: now, window: policy.Window}
+		s.buckets[key] = b
+	}
+
+	tokens, result := Take(policy, b.tokens, now.Sub(b.updatedAt))
+	b.tokens = tokens
+	b.updatedAt = now
+	b.window = policy.Window
+	return result, nil
+}
+
+// drops buckets idle long enough to be full again
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < SweepInterval {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.Sub(b.updatedAt) >= b.window {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
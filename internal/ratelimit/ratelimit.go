@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// represents a token bucket policy: up to Limit requests per Window,
+// refilled evenly over the window
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// represents the outcome of taking a token from a bucket
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+	Reset      time.Duration
+}
+
+// represents a storage of token buckets
+type Store interface {
+	// takes a token from the bucket of a key
+	TakeRateLimitToken(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// refills a bucket holding tokens for the elapsed time and takes a token from it,
+// returning the tokens left and the result
+func Take(policy Policy, tokens float64, elapsed time.Duration) (float64, Result) {
+	limit := float64(policy.Limit)
+	perToken := policy.Window / time.Duration(policy.Limit)
+
+	if elapsed > 0 {
+		tokens = math.Min(limit, tokens+elapsed.Seconds()/perToken.Seconds())
+	}
+
+	result := Result{Limit: policy.Limit}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+
+	result.Remaining = int(tokens)
+	result.Reset = time.Duration((limit - tokens) * float64(perToken))
+	return tokens, result
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gophermart/internal/models"
+)
+
+// gets all changes of a user balance in chronological order
+func (r *Repository) GetBalanceHistory(ctx context.Context, userID int64) ([]models.BalanceEntry, error) {
+	query := `
+		SELECT 'accrual', accrual, number, '', updated_at
+		FROM orders
+		WHERE user_id = $1 AND status = 'PROCESSED' AND accrual > 0
+		UNION ALL
+		SELECT 'withdrawal', -sum, order_number, '', created_at
+		FROM withdrawals
+		WHERE user_id = $1
+		UNION ALL
+		SELECT 'adjustment', amount, '', reason, created_at
+		FROM balance_adjustments
+		WHERE user_id = $1
+		ORDER BY 5 ASC`
+
+	rows, err := r.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.BalanceEntry
+	for rows.Next() {
+		var e models.BalanceEntry
+		if err := rows.Scan(&e.Type, &e.Amount, &e.Order, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan balance entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating balance history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// strips personal data from a user and terminates its sessions, financial records are kept
+func (r *Repository) AnonymizeUser(ctx context.Context, userID int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users
+		SET login = $3::text || id,
+			password_hash = '',
+			totp_secret = NULL,
+			totp_enabled = FALSE,
+			totp_last_step = NULL,
+			roles = '{}',
+			deleted_at = $1
+		WHERE id = $2 AND deleted_at IS NULL`,
+		time.Now(), userID, models.DeletedLoginPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("failed to anonymize user %d: %w", userID, ErrUserNotFound)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	// removing sessions revokes every token issued to the user
+	_, err = tx.Exec(ctx, `
+		DELETE FROM sessions WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+
+	event, err := newAuditEvent(ctx, models.AuditUserDeleted, userID, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gophermart/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const apiKeyColumns = `id, name, prefix, scopes, created_by, created_at, revoked_at, last_used_at, last_used_ip, usage_count`
+
+// scans an API key row
+func scanAPIKey(row pgx.Row, key *models.APIKey, extra ...interface{}) error {
+	dest := []interface{}{
+		&key.ID,
+		&key.Name,
+		&key.Prefix,
+		&key.Scopes,
+		&key.CreatedBy,
+		&key.CreatedAt,
+		&key.RevokedAt,
+		&key.LastUsedAt,
+		&key.LastUsedIP,
+		&key.UsageCount,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// creates a new API key
+func (r *Repository) CreateAPIKey(ctx context.Context, key *models.APIKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + apiKeyColumns
+
+	err := scanAPIKey(r.db.QueryRow(ctx, query, key.Name, key.Prefix, keyHash, key.Scopes, key.CreatedBy, time.Now()), key)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+// gets a list of all API keys
+func (r *Repository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// gets an API key and its hash by public prefix
+func (r *Repository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, string, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `, key_hash
+		FROM api_keys
+		WHERE prefix = $1`
+
+	key := &models.APIKey{}
+	var keyHash string
+	err := scanAPIKey(r.db.QueryRow(ctx, query, prefix), key, &keyHash)
+	if err == pgx.ErrNoRows {
+		return nil, "", ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return key, keyHash, nil
+}
+
+// revokes an API key
+func (r *Repository) RevokeAPIKey(ctx context.Context, id int64) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE api_keys
+		SET revoked_at = $1
+		WHERE id = $2 AND revoked_at IS NULL`,
+		time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// records a successful API key use
+func (r *Repository) RecordAPIKeyUsage(ctx context.Context, id int64, ip string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE api_keys
+		SET usage_count = usage_count + 1, last_used_at = $1, last_used_ip = $2
+		WHERE id = $3`,
+		time.Now(), ip, id)
+	if err != nil {
+		return fmt.Errorf("failed to record api key usage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gophermart/internal/models"
+	"gophermart/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// advisory lock serializing audit appends so that the hash chain never forks
+const auditLockKey = 0x617564697400
+
+// previous hash of the first audit event
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// builds an audit event for the actor, IP and request found in the context
+func newAuditEvent(ctx context.Context, eventType string, subjectID int64, before, after interface{}) (*models.AuditEvent, error) {
+	event := &models.AuditEvent{Type: eventType}
+	if subjectID != 0 {
+		event.SubjectID = &subjectID
+	}
+	if actorID, ok := utils.GetUserID(ctx); ok {
+		event.ActorID = &actorID
+	}
+	if keyID, ok := utils.GetAPIKeyID(ctx); ok {
+		event.APIKeyID = &keyID
+	}
+	event.IP, _ = utils.GetClientIP(ctx)
+	event.RequestID, _ = utils.GetRequestID(ctx)
+
+	var err error
+	if before != nil {
+		if event.Before, err = json.Marshal(before); err != nil {
+			return nil, fmt.Errorf("failed to encode audit event: %w", err)
+		}
+	}
+	if after != nil {
+		if event.After, err = json.Marshal(after); err != nil {
+			return nil, fmt.Errorf("failed to encode audit event: %w", err)
+		}
+	}
+	return event, nil
+}
+
+// appends an audit event to the chain within a transaction
+func appendAuditEvent(ctx context.Context, tx pgx.Tx, event *models.AuditEvent) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+
+	err := tx.QueryRow(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&event.PrevHash)
+	if err == pgx.ErrNoRows {
+		event.PrevHash = auditGenesisHash
+	} else if err != nil {
+		return fmt.Errorf("failed to get last audit event: %w", err)
+	}
+
+	// the database keeps microseconds, the hash must cover the stored value
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	event.Hash = auditHash(event)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO audit_events (type, actor_id, api_key_id, subject_id, ip, request_id, before, after, created_at, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id`,
+		event.Type, event.ActorID, event.APIKeyID, event.SubjectID, event.IP, event.RequestID,
+		nullableJSON(event.Before), nullableJSON(event.After), event.CreatedAt, event.PrevHash, event.Hash).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create audit event: %w", err)
+	}
+	return nil
+}
+
+// computes the hash of an audit event chained to the previous one
+func auditHash(event *models.AuditEvent) string {
+	fields := []string{
+		event.PrevHash,
+		event.Type,
+		optionalID(event.ActorID),
+		optionalID(event.SubjectID),
+		event.IP,
+		event.RequestID,
+		string(event.Before),
+		string(event.After),
+		strconv.FormatInt(event.CreatedAt.UnixMicro(), 10),
+	}
+	// the API key was added later and is hashed only when set, so that earlier events still verify
+	if event.APIKeyID != nil {
+		fields = append(fields, optionalID(event.APIKeyID))
+	}
+
+	h := sha256.New()
+	for _, field := range fields {
+		// length prefixes keep field boundaries unambiguous
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// formats an optional ID for hashing
+func optionalID(id *int64) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
+
+// passes JSON verbatim, or NULL when there is none
+func nullableJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+// records an audit event that does not accompany a change, such as a failed login
+func (r *Repository) RecordAuditEvent(ctx context.Context, eventType string, subjectID int64, details interface{}) error {
+	event, err := newAuditEvent(ctx, eventType, subjectID, nil, details)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// scans an audit event row
+func scanAuditEvent(row pgx.Row, event *models.AuditEvent) error {
+	var before, after *string
+	err := row.Scan(&event.ID, &event.Type, &event.ActorID, &event.APIKeyID, &event.SubjectID, &event.IP, &event.RequestID,
+		&before, &after, &event.CreatedAt, &event.PrevHash, &event.Hash)
+	if err != nil {
+		return err
+	}
+	if before != nil {
+		event.Before = json.RawMessage(*before)
+	}
+	if after != nil {
+		event.After = json.RawMessage(*after)
+	}
+	return nil
+}
+
+// columns read by scanAuditEvent
+const auditEventColumns = `id, type, actor_id, api_key_id, subject_id, ip, request_id, before::text, after::text, created_at, prev_hash, hash`
+
+// gets audit events matching a filter, oldest first
+func (r *Repository) GetAuditEvents(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+auditEventColumns+`
+		FROM audit_events
+		WHERE id > $1
+			AND ($2 = '' OR type = $2)
+			AND ($3 = 0 OR actor_id = $3 OR subject_id = $3)
+		ORDER BY id
+		LIMIT $4`,
+		filter.AfterID, filter.Type, filter.UserID, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := scanAuditEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// walks the whole audit chain, recomputing every hash
+func (r *Repository) VerifyAuditChain(ctx context.Context) (*models.AuditVerification, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+auditEventColumns+` FROM audit_events ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.AuditVerification{Valid: true}
+	prevHash := auditGenesisHash
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := scanAuditEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		result.Checked++
+
+		switch {
+		case event.PrevHash != prevHash:
+			result.Error = "previous hash does not match, an event was removed or reordered"
+		case auditHash(&event) != event.Hash:
+			result.Error = "hash does not match the event content"
+		default:
+			prevHash = event.Hash
+			continue
+		}
+		result.Valid = false
+		result.FirstInvalidID = &event.ID
+		break
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"gophermart/internal/models"
+)
+
+func TestAuditHashCoversAPIKey(t *testing.T) {
+	actorID, keyID := int64(42), int64(7)
+	event := &models.AuditEvent{
+		Type:      models.AuditBalanceWithdrawn,
+		ActorID:   &actorID,
+		SubjectID: &actorID,
+		CreatedAt: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		PrevHash:  auditGenesisHash,
+	}
+	own := auditHash(event)
+
+	event.APIKeyID = &keyID
+	if partner := auditHash(event); partner == own {
+		t.Error("hash of an event made with an API key equals the hash of the user's own event")
+	}
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"gophermart/internal/models"
+)
+
+// gets user order events recorded after an event ID, oldest first
+func (r *Repository) GetOrderEvents(ctx context.Context, userID int64, afterID int64, limit int) ([]models.OrderEvent, error) {
+	query := `
+		SELECT id, user_id, type, order_number, status, amount, created_at
+		FROM order_events
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id ASC
+		LIMIT $3`
+
+	rows, err := r.db.Query(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.OrderEvent
+	for rows.Next() {
+		var event models.OrderEvent
+		err := rows.Scan(&event.ID, &event.UserID, &event.Type, &event.Order, &event.Status, &event.Amount, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating order events: %w", err)
+	}
+
+	return events, nil
+}
+
+// gets the ID of the latest user order event, or zero if there is none
+func (r *Repository) GetLatestOrderEventID(ctx context.Context, userID int64) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `
+		SELECT COALESCE(MAX(id), 0) FROM order_events WHERE user_id = $1`, userID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest order event: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// channel notified with the number of every uploaded order
+const newOrdersChannel = "new_orders"
+
+// listens for uploaded orders on a dedicated connection, calling onListen once listening
+// and onOrder for every notification; blocks until the connection fails or ctx is done
+func (r *Repository) ListenNewOrders(ctx context.Context, onListen func(), onOrder func(number string)) error {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire listen connection: %w", err)
+	}
+	// the connection may be left in a broken or listening state, so it is not returned to the pool
+	listenConn := conn.Hijack()
+	defer listenConn.Close(context.Background())
+
+	if _, err := listenConn.Exec(ctx, "LISTEN "+newOrdersChannel); err != nil {
+		return fmt.Errorf("failed to listen for new orders: %w", err)
+	}
+	onListen()
+
+	for {
+		notification, err := listenConn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		onOrder(notification.Payload)
+	}
+}
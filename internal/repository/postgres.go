@@ -2,53 +2,144 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"gophermart/internal/metrics"
 	"gophermart/internal/models"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrAPIKeyNotFound    = errors.New("api key not found")
+	ErrUserNotFound      = errors.New("user not found")
+)
+
 // represents a data access layer
 type Repository struct {
 	db *pgxpool.Pool
+	// tables created by the migration
+	tables []string
+	// credentials for new connections, replaced when the database password is rotated
+	credentials atomic.Pointer[credentials]
+	// unix nanoseconds of the last removal of expired rate limit buckets
+	rateLimitSweep atomic.Int64
 }
 
-func initDatabase(ctx context.Context, db *pgxpool.Pool) error {
+// represents database login credentials
+type credentials struct {
+	user     string
+	password string
+}
+
+// matches the tables created by a migration
+var createTableRe = regexp.MustCompile(`(?i)CREATE TABLE IF NOT EXISTS (\w+)`)
+
+// applies the migration, returning the tables it creates
+func initDatabase(ctx context.Context, db *pgxpool.Pool) ([]string, error) {
 
 	migrationSQL, err := os.ReadFile("migrations/001_init.sql")
 	if err != nil {
-		return fmt.Errorf("failed to read migration file: %w", err)
+		return nil, fmt.Errorf("failed to read migration file: %w", err)
 	}
 
 	_, err = db.Exec(ctx, string(migrationSQL))
 	if err != nil {
-		return fmt.Errorf("failed to execute migration: %w", err)
+		return nil, fmt.Errorf("failed to execute migration: %w", err)
 	}
 
-	return nil
+	var tables []string
+	for _, m := range createTableRe.FindAllStringSubmatch(string(migrationSQL), -1) {
+		tables = append(tables, m[1])
+	}
+	return tables, nil
 }
 
-// creates a new repository
-func NewRepository(databaseURI string) (*Repository, error) {
-	pool, err := pgxpool.New(context.Background(), databaseURI)
+// creates a new repository with a connection pool of at most maxConns connections, keeping minConns open
+func NewRepository(databaseURI string, maxConns, minConns int32) (*Repository, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse database URI: %w", err)
+	}
+	poolConfig.MaxConns = maxConns
+	poolConfig.MinConns = minConns
+	poolConfig.ConnConfig.Tracer = queryTracer{}
+
+	repo := &Repository{}
+	repo.credentials.Store(&credentials{user: poolConfig.ConnConfig.User, password: poolConfig.ConnConfig.Password})
+	poolConfig.BeforeConnect = func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		creds := repo.credentials.Load()
+		connConfig.User = creds.user
+		connConfig.Password = creds.password
+		return nil
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection pool: %w", err)
 	}
+	repo.db = pool
 
 	if err := pool.Ping(context.Background()); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
 	// Инициализируем базу данных
-	if err := initDatabase(context.Background(), pool); err != nil {
+	tables, err := initDatabase(context.Background(), pool)
+	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %w", err)
 	}
 
-	return &Repository{db: pool}, nil
+	repo.tables = tables
+	return repo, nil
+}
+
+// replaces the user and password used for new connections with the ones in databaseURI,
+// open connections are kept; other connection settings need a restart
+func (r *Repository) SetCredentials(databaseURI string) error {
+	connConfig, err := pgx.ParseConfig(databaseURI)
+	if err != nil {
+		return fmt.Errorf("failed to parse database URI: %w", err)
+	}
+	r.credentials.Store(&credentials{user: connConfig.User, password: connConfig.Password})
+	return nil
+}
+
+// checks that the database answers
+func (r *Repository) Ping(ctx context.Context) error {
+	return r.db.Ping(ctx)
+}
+
+// checks that every table created by the migration exists
+func (r *Repository) CheckMigrations(ctx context.Context) error {
+	rows, err := r.db.Query(ctx, `
+		SELECT name FROM unnest($1::text[]) AS name
+		WHERE to_regclass(name) IS NULL`, r.tables)
+	if err != nil {
+		return fmt.Errorf("failed to check tables: %w", err)
+	}
+	missing, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("failed to check tables: %w", err)
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing tables: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// returns connection pool statistics
+func (r *Repository) PoolStat() *pgxpool.Stat {
+	return r.db.Stat()
 }
 
 // closes the connection to the database
@@ -61,32 +152,60 @@ func (r *Repository) Close() {
 // creates a new user
 func (r *Repository) CreateUser(ctx context.Context, user *models.User) error {
 	query := `
-		INSERT INTO users (login, password_hash, created_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO users (login, password_hash, roles, created_at)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id`
 
-	err := r.db.QueryRow(ctx, query, user.Login, user.PasswordHash, time.Now()).Scan(&user.ID)
+	if len(user.Roles) == 0 {
+		user.Roles = []string{models.RoleUser}
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, query, user.Login, user.PasswordHash, user.Roles, time.Now()).Scan(&user.ID)
 	if err != nil {
 		if err.Error() == "ERROR: duplicate key value violates unique constraint \"users_login_key\" (SQLSTATE 23505)" {
 			return fmt.Errorf("user with login %s already exists", user.Login)
 		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
-	return nil
+
+	// the login is left out: audit events outlive account deletion
+	event, err := newAuditEvent(ctx, models.AuditUserRegistered, user.ID, nil, map[string]interface{}{
+		"roles": user.Roles,
+	})
+	if err != nil {
+		return err
+	}
+	// a new user registers on their own behalf
+	event.ActorID = &user.ID
+	if err := appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 // gets a user by login
 func (r *Repository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
 	query := `
-		SELECT id, login, password_hash, created_at
+		SELECT id, login, password_hash, COALESCE(totp_secret, ''), totp_enabled, totp_locked_until, roles, created_at
 		FROM users
-		WHERE login = $1`
+		WHERE login = $1 AND deleted_at IS NULL`
 
 	user := &models.User{}
 	err := r.db.QueryRow(ctx, query, login).Scan(
 		&user.ID,
 		&user.Login,
 		&user.PasswordHash,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLockedUntil,
+		&user.Roles,
 		&user.CreatedAt,
 	)
 	if err == pgx.ErrNoRows {
@@ -99,6 +218,166 @@ func (r *Repository) GetUserByLogin(ctx context.Context, login string) (*models.
 	return user, nil
 }
 
+// gets a user by ID
+func (r *Repository) GetUserByID(ctx context.Context, userID int64) (*models.User, error) {
+	query := `
+		SELECT id, login, password_hash, COALESCE(totp_secret, ''), totp_enabled, totp_locked_until, roles, created_at
+		FROM users
+		WHERE id = $1 AND deleted_at IS NULL`
+
+	user := &models.User{}
+	err := r.db.QueryRow(ctx, query, userID).Scan(
+		&user.ID,
+		&user.Login,
+		&user.PasswordHash,
+		&user.TOTPSecret,
+		&user.TOTPEnabled,
+		&user.TOTPLockedUntil,
+		&user.Roles,
+		&user.CreatedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+
+	return user, nil
+}
+
+// updates a user password hash
+func (r *Repository) UpdateUserPasswordHash(ctx context.Context, userID int64, passwordHash string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE users SET password_hash = $1 WHERE id = $2`,
+		passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update password hash: %w", err)
+	}
+	return nil
+}
+
+// stores a pending TOTP secret for a user
+func (r *Repository) SetTOTPSecret(ctx context.Context, userID int64, secret string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE users
+		SET totp_secret = $1, totp_enabled = FALSE, totp_last_step = NULL
+		WHERE id = $2`,
+		secret, userID)
+	if err != nil {
+		return fmt.Errorf("failed to set TOTP secret: %w", err)
+	}
+	return nil
+}
+
+// enables two-factor authentication and replaces the user recovery codes
+func (r *Repository) EnableTOTP(ctx context.Context, userID int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET totp_enabled = TRUE WHERE id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable TOTP: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO recovery_codes (user_id, code_hash, created_at)
+			VALUES ($1, $2, $3)`,
+			userID, codeHash, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to create recovery code: %w", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// disables two-factor authentication and removes the user recovery codes
+func (r *Repository) DisableTOTP(ctx context.Context, userID int64) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = NULL
+		WHERE id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to disable TOTP: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// marks a TOTP time step as used, returns false if it or a later step was already used
+func (r *Repository) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE users
+		SET totp_last_step = $1
+		WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)`,
+		step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to use TOTP step: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// counts a wrong two-factor code; reaching maxFailures locks two-factor checks until lockedUntil and starts counting anew
+func (r *Repository) RecordTOTPFailure(ctx context.Context, userID int64, maxFailures int, lockedUntil time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE users
+		SET totp_failed_attempts = CASE WHEN totp_failed_attempts + 1 >= $2 THEN 0 ELSE totp_failed_attempts + 1 END,
+			totp_locked_until = CASE WHEN totp_failed_attempts + 1 >= $2 THEN $3 ELSE totp_locked_until END
+		WHERE id = $1`,
+		userID, maxFailures, lockedUntil)
+	if err != nil {
+		return fmt.Errorf("failed to record TOTP failure: %w", err)
+	}
+	return nil
+}
+
+// clears the count of wrong two-factor codes after a correct one
+func (r *Repository) ResetTOTPFailures(ctx context.Context, userID int64) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE users SET totp_failed_attempts = 0 WHERE id = $1 AND totp_failed_attempts > 0`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reset TOTP failures: %w", err)
+	}
+	return nil
+}
+
+// marks a recovery code as used, returns false if it does not exist or was already used
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE recovery_codes
+		SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`,
+		time.Now(), userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
 // creates a new order
 func (r *Repository) CreateOrder(ctx context.Context, userID int, number string) error {
 	// check if order exists
@@ -126,11 +405,15 @@ func (r *Repository) CreateOrder(ctx context.Context, userID int, number string)
 		return fmt.Errorf("order with number %s already exists for another user", number)
 	}
 
-	// create a new order
+	// create a new order and wake up the accrual workers
 	_, err = r.db.Exec(ctx, `
-		INSERT INTO orders (user_id, number, status, uploaded_at)
-		VALUES ($1, $2, $3, $4)`,
-		userID, number, "NEW", time.Now())
+		WITH created AS (
+			INSERT INTO orders (user_id, number, status, uploaded_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING number
+		)
+		SELECT pg_notify($5, number) FROM created`,
+		userID, number, "NEW", time.Now(), newOrdersChannel)
 	if err != nil {
 		return fmt.Errorf("failed to create order: %w", err)
 	}
@@ -138,6 +421,86 @@ func (r *Repository) CreateOrder(ctx context.Context, userID int, number string)
 	return nil
 }
 
+// creates new orders in one transaction, skipping existing numbers;
+// returns the owner user IDs of the numbers that already existed
+func (r *Repository) CreateOrders(ctx context.Context, userID int, numbers []string) (map[string]int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		INSERT INTO orders (user_id, number, status, uploaded_at)
+		SELECT $1, number, 'NEW', $3
+		FROM unnest($2::text[]) AS number
+		ON CONFLICT (number) DO NOTHING
+		RETURNING number`,
+		userID, numbers, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create orders: %w", err)
+	}
+
+	created := make(map[string]bool, len(numbers))
+	for rows.Next() {
+		var number string
+		if err := rows.Scan(&number); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan order number: %w", err)
+		}
+		created[number] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating created orders: %w", err)
+	}
+
+	var fresh, existing []string
+	for _, number := range numbers {
+		if created[number] {
+			fresh = append(fresh, number)
+		} else {
+			existing = append(existing, number)
+		}
+	}
+
+	// notifications are delivered to the accrual workers on commit
+	_, err = tx.Exec(ctx, `
+		SELECT pg_notify($1, number) FROM unnest($2::text[]) AS number`,
+		newOrdersChannel, fresh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to notify about new orders: %w", err)
+	}
+
+	owners := make(map[string]int, len(existing))
+	if len(existing) > 0 {
+		rows, err := tx.Query(ctx, `
+			SELECT number, user_id FROM orders WHERE number = ANY($1)`, existing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get order owners: %w", err)
+		}
+
+		for rows.Next() {
+			var number string
+			var ownerID int
+			if err := rows.Scan(&number, &ownerID); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan order owner: %w", err)
+			}
+			owners[number] = ownerID
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("error iterating order owners: %w", err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return owners, nil
+}
+
 // gets a list of user orders
 func (r *Repository) GetUserOrders(ctx context.Context, userID int) ([]models.Order, error) {
 	query := `
@@ -206,15 +569,12 @@ func (r *Repository) CreateWithdrawal(ctx context.Context, userID int, orderNumb
 	defer tx.Rollback(ctx)
 
 	// check if user has enough funds
-	var currentBalance float32
-	err = tx.QueryRow(ctx, `
-		SELECT current_balance FROM user_balances WHERE user_id = $1`,
-		userID).Scan(&currentBalance)
+	before, err := lockBalanceTx(ctx, tx, int64(userID))
 	if err != nil {
-		return fmt.Errorf("failed to get user balance: %w", err)
+		return err
 	}
 
-	if currentBalance < sum {
+	if before.Current < sum {
 		return fmt.Errorf("insufficient funds")
 	}
 
@@ -228,16 +588,30 @@ func (r *Repository) CreateWithdrawal(ctx context.Context, userID int, orderNumb
 	}
 
 	// update user balance
-	_, err = tx.Exec(ctx, `
+	after := &models.UserBalance{}
+	err = tx.QueryRow(ctx, `
 		UPDATE user_balances 
 		SET current_balance = current_balance - $1, withdrawn_balance = withdrawn_balance + $1, updated_at = $2
-		WHERE user_id = $3`,
-		sum, time.Now(), userID)
+		WHERE user_id = $3
+		RETURNING current_balance, withdrawn_balance`,
+		sum, time.Now(), userID).Scan(&after.Current, &after.Withdrawn)
 	if err != nil {
 		return fmt.Errorf("failed to update user balance: %w", err)
 	}
 
-	return tx.Commit(ctx)
+	event, err := newAuditEvent(ctx, models.AuditBalanceWithdrawn, int64(userID), before, balanceChange{UserBalance: after, Order: orderNumber, Amount: -sum})
+	if err != nil {
+		return err
+	}
+	if err := appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	metrics.PointsWithdrawn.Add(float64(sum))
+	return nil
 }
 
 // gets a user withdrawal history
@@ -302,90 +676,279 @@ func (r *Repository) GetProcessingOrders(ctx context.Context) ([]models.Order, e
 	return orders, nil
 }
 
-// updates order status and accrual
-func (r *Repository) UpdateOrderStatus(ctx context.Context, number string, status string, accrual float32) error {
+// updates order status and accrual, recording the resulting order events;
+// returns no events if the order did not change
+func (r *Repository) UpdateOrderStatus(ctx context.Context, number string, status string, accrual float32) ([]models.OrderEvent, error) {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// update order status and accrual
-	_, err = tx.Exec(ctx, `
+	// update order status and accrual; final orders are never updated again, so that only the
+	// call making the transition credits the accrual, however often the order is checked
+	var userID int64
+	err = tx.QueryRow(ctx, `
 		UPDATE orders 
 		SET status = $1, accrual = $2, updated_at = $3
-		WHERE number = $4`,
-		status, accrual, time.Now(), number)
+		WHERE number = $4 AND status <> $1 AND status NOT IN ($5, $6)
+		RETURNING user_id`,
+		status, accrual, time.Now(), number, models.OrderStatusProcessed, models.OrderStatusInvalid).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update order status: %w", err)
+		return nil, fmt.Errorf("failed to update order status: %w", err)
 	}
 
+	events := []models.OrderEvent{{UserID: userID, Type: models.OrderEventStatus, Order: number, Status: status, Amount: accrual}}
+
 	// if order is processed and there is an accrual, update user balance
 	if status == "PROCESSED" && accrual > 0 {
-		_, err = tx.Exec(ctx, `
-			UPDATE user_balances 
-			SET current_balance = current_balance + $1, updated_at = $2
-			WHERE user_id = (
-				SELECT user_id FROM orders WHERE number = $3
-			)`,
-			accrual, time.Now(), number)
+		before, err := lockBalanceTx(ctx, tx, userID)
 		if err != nil {
-			return fmt.Errorf("failed to update user balance: %w", err)
+			return nil, err
 		}
-	}
 
-	return tx.Commit(ctx)
-}
+		after, err := changeBalanceTx(ctx, tx, userID, accrual)
+		if err != nil {
+			return nil, err
+		}
 
-// updates user balance
-func (r *Repository) UpdateUserBalance(ctx context.Context, userID int, amount float32) error {
-	// check if balance exists
-	var exists bool
-	err := r.db.QueryRow(ctx, `
-		SELECT EXISTS(SELECT 1 FROM user_balances WHERE user_id = $1)`, userID).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("failed to check balance existence: %w", err)
+		event, err := newAuditEvent(ctx, models.AuditBalanceCredited, userID, before, balanceChange{UserBalance: after, Order: number, Amount: accrual})
+		if err != nil {
+			return nil, err
+		}
+		if err := appendAuditEvent(ctx, tx, event); err != nil {
+			return nil, err
+		}
+		events = append(events, models.OrderEvent{UserID: userID, Type: models.OrderEventAccrual, Order: number, Amount: accrual})
 	}
 
-	if !exists {
-		// if record does not exist, create a new one
-		_, err = r.db.Exec(ctx, `
-			INSERT INTO user_balances (user_id, current_balance, withdrawn_balance)
-			VALUES ($1, $2, 0)`, userID, amount)
+	for i := range events {
+		err = tx.QueryRow(ctx, `
+			INSERT INTO order_events (user_id, type, order_number, status, amount)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, created_at`,
+			events[i].UserID, events[i].Type, events[i].Order, events[i].Status, events[i].Amount).Scan(&events[i].ID, &events[i].CreatedAt)
 		if err != nil {
-			return fmt.Errorf("failed to create user balance: %w", err)
+			return nil, fmt.Errorf("failed to create order event: %w", err)
 		}
-		return nil
 	}
 
-	// update existing balance
-	_, err = r.db.Exec(ctx, `
-		UPDATE user_balances
-		SET current_balance = current_balance + $1, updated_at = $2
-		WHERE user_id = $3`, amount, time.Now(), userID)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if status == "PROCESSED" && accrual > 0 {
+		metrics.PointsCredited.Add(float64(accrual))
+	}
+	return events, nil
+}
+
+// claims an order not yet final for lease, returning its owner; ok is false when the order
+// is final, missing or claimed by another worker
+func (r *Repository) ClaimOrder(ctx context.Context, number string, lease time.Duration) (userID int, ok bool, err error) {
+	err = r.db.QueryRow(ctx, `
+		UPDATE orders
+		SET claimed_until = now() + $2::interval
+		WHERE number = $1 AND status IN ($3, $4)
+			AND (claimed_until IS NULL OR claimed_until < now())
+		RETURNING user_id`,
+		number, lease, models.OrderStatusNew, models.OrderStatusProcessing).Scan(&userID)
+	if err == pgx.ErrNoRows {
+		return 0, false, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update user balance: %w", err)
+		return 0, false, fmt.Errorf("failed to claim order: %w", err)
 	}
+	return userID, true, nil
+}
 
+// releases a claim on an order
+func (r *Repository) ReleaseOrder(ctx context.Context, number string) error {
+	_, err := r.db.Exec(ctx, `UPDATE orders SET claimed_until = NULL WHERE number = $1`, number)
+	if err != nil {
+		return fmt.Errorf("failed to release order: %w", err)
+	}
 	return nil
 }
 
-// checks if order exists and returns a user ID
-func (r *Repository) CheckOrderExists(ctx context.Context, orderNumber string) (int, error) {
+// gets an order by number
+func (r *Repository) GetOrderByNumber(ctx context.Context, number string) (*models.Order, error) {
 	query := `
-		SELECT user_id
+		SELECT number, status, accrual, uploaded_at
 		FROM orders
-		WHERE number = $1
-	`
+		WHERE number = $1`
 
-	var userID int
-	err := r.db.QueryRow(ctx, query, orderNumber).Scan(&userID)
+	order := &models.Order{}
+	err := r.db.QueryRow(ctx, query, number).Scan(&order.Number, &order.Status, &order.Accrual, &order.CreatedAt)
 	if err == pgx.ErrNoRows {
-		return 0, nil
+		return nil, ErrOrderNotFound
 	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to check order existence: %w", err)
+		return nil, fmt.Errorf("failed to get order: %w", err)
 	}
 
-	return userID, nil
+	return order, nil
+}
+
+// adds a signed amount to a user balance and records who made the adjustment
+func (r *Repository) AdjustUserBalance(ctx context.Context, userID, adminID int64, amount float32, reason string) (*models.UserBalance, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockBalanceTx(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := changeBalanceTx(ctx, tx, userID, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO balance_adjustments (user_id, admin_id, amount, reason, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		userID, adminID, amount, reason, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create balance adjustment: %w", err)
+	}
+
+	event, err := newAuditEvent(ctx, models.AuditBalanceAdjusted, userID, before, balanceChange{UserBalance: balance, Amount: amount, Reason: reason})
+	if err != nil {
+		return nil, err
+	}
+	event.ActorID = &adminID
+	if err := appendAuditEvent(ctx, tx, event); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return balance, nil
+}
+
+// sets order status and accrual, correcting the user balance by the change in credited points
+func (r *Repository) OverrideOrderStatus(ctx context.Context, number string, status string, accrual float32) (*models.Order, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var userID int64
+	var oldStatus string
+	var oldAccrual float32
+	err = tx.QueryRow(ctx, `
+		SELECT user_id, status, accrual FROM orders WHERE number = $1 FOR UPDATE`,
+		number).Scan(&userID, &oldStatus, &oldAccrual)
+	if err == pgx.ErrNoRows {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	order := &models.Order{}
+	err = tx.QueryRow(ctx, `
+		UPDATE orders
+		SET status = $1, accrual = $2, updated_at = $3
+		WHERE number = $4
+		RETURNING number, status, accrual, uploaded_at`,
+		status, accrual, time.Now(), number).Scan(&order.Number, &order.Status, &order.Accrual, &order.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+
+	// only processed orders contribute to the balance
+	var delta float32
+	if oldStatus == models.OrderStatusProcessed {
+		delta -= oldAccrual
+	}
+	if status == models.OrderStatusProcessed {
+		delta += accrual
+	}
+
+	if delta != 0 {
+		if _, err := changeBalanceTx(ctx, tx, userID, delta); err != nil {
+			return nil, err
+		}
+	}
+
+	event, err := newAuditEvent(ctx, models.AuditOrderOverridden, userID,
+		orderChange{Order: number, Status: oldStatus, Accrual: oldAccrual},
+		orderChange{Order: number, Status: order.Status, Accrual: order.Accrual, BalanceDelta: delta})
+	if err != nil {
+		return nil, err
+	}
+	if err := appendAuditEvent(ctx, tx, event); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return order, nil
+}
+
+// adds a signed amount to a user balance inside a transaction without letting it go negative
+func changeBalanceTx(ctx context.Context, tx pgx.Tx, userID int64, amount float32) (*models.UserBalance, error) {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO user_balances (user_id, current_balance, withdrawn_balance)
+		SELECT $1, 0, 0
+		WHERE NOT EXISTS (SELECT 1 FROM user_balances WHERE user_id = $1)`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user balance: %w", err)
+	}
+
+	balance := &models.UserBalance{}
+	err = tx.QueryRow(ctx, `
+		UPDATE user_balances
+		SET current_balance = current_balance + $1, updated_at = $2
+		WHERE user_id = $3 AND current_balance + $1 >= 0
+		RETURNING current_balance, withdrawn_balance`,
+		amount, time.Now(), userID).Scan(&balance.Current, &balance.Withdrawn)
+	if err == pgx.ErrNoRows {
+		return nil, ErrInsufficientFunds
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// locks a user balance row for the rest of the transaction and returns it, zero if there is none
+func lockBalanceTx(ctx context.Context, tx pgx.Tx, userID int64) (*models.UserBalance, error) {
+	balance := &models.UserBalance{}
+	err := tx.QueryRow(ctx, `
+		SELECT current_balance, withdrawn_balance FROM user_balances WHERE user_id = $1 FOR UPDATE`,
+		userID).Scan(&balance.Current, &balance.Withdrawn)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("failed to get user balance: %w", err)
+	}
+	return balance, nil
+}
+
+// represents a balance after a change, as recorded in the audit log
+type balanceChange struct {
+	*models.UserBalance
+	Order  string  `json:"order,omitempty"`
+	Amount float32 `json:"amount"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// represents an order state, as recorded in the audit log
+type orderChange struct {
+	Order        string  `json:"order"`
+	Status       string  `json:"status"`
+	Accrual      float32 `json:"accrual"`
+	BalanceDelta float32 `json:"balance_delta,omitempty"`
 }
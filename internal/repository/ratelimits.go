@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gophermart/internal/ratelimit"
+)
+
+// takes a token from a rate limit bucket shared by all replicas
+func (r *Repository) TakeRateLimitToken(ctx context.Context, key string, policy ratelimit.Policy) (ratelimit.Result, error) {
+	r.sweepRateLimits(ctx)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO rate_limits (key, tokens, updated_at, expires_at)
+		VALUES ($1, $2, now(), now() + $3::interval)
+		ON CONFLICT (key) DO NOTHING`, key, policy.Limit, policy.Window)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("failed to create rate limit bucket: %w", err)
+	}
+
+	// the database clock is used so that replicas agree on elapsed time
+	var tokens float64
+	var updatedAt, now time.Time
+	err = tx.QueryRow(ctx, `
+		SELECT tokens, updated_at, now()
+		FROM rate_limits
+		WHERE key = $1
+		FOR UPDATE`, key).Scan(&tokens, &updatedAt, &now)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("failed to get rate limit bucket: %w", err)
+	}
+
+	tokens, result := ratelimit.Take(policy, tokens, now.Sub(updatedAt))
+
+	_, err = tx.Exec(ctx, `
+		UPDATE rate_limits SET tokens = $2, updated_at = $3, expires_at = $4 WHERE key = $1`,
+		key, tokens, now, now.Add(policy.Window))
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("failed to update rate limit bucket: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return ratelimit.Result{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return result, nil
+}
+
+// drops buckets idle long enough to be full again, at most once per sweep interval on each replica
+func (r *Repository) sweepRateLimits(ctx context.Context) {
+	now := time.Now()
+	last := r.rateLimitSweep.Load()
+	if now.Sub(time.Unix(0, last)) < ratelimit.SweepInterval || !r.rateLimitSweep.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+
+	if _, err := r.db.Exec(ctx, `DELETE FROM rate_limits WHERE expires_at < now()`); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete expired rate limit buckets", "error", err)
+	}
+}
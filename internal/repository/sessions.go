@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gophermart/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// creates a new session
+func (r *Repository) CreateSession(ctx context.Context, session *models.Session) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+	_, err = tx.Exec(ctx, `
+		INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $5)`,
+		session.ID, session.UserID, session.UserAgent, session.IP, now)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+
+	// a session is only started by a successful login or registration
+	event, err := newAuditEvent(ctx, models.AuditLoginSucceeded, session.UserID, nil, map[string]interface{}{
+		"session_id": session.ID,
+	})
+	if err != nil {
+		return err
+	}
+	event.ActorID = &session.UserID
+	if err := appendAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	session.CreatedAt = now
+	session.LastSeenAt = now
+	return nil
+}
+
+// gets a session by ID
+func (r *Repository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at, revoked_at
+		FROM sessions
+		WHERE id = $1`
+
+	session := &models.Session{}
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.UserAgent,
+		&session.IP,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.RevokedAt,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return session, nil
+}
+
+// gets a list of user sessions that are active and created after a moment
+func (r *Repository) GetActiveSessions(ctx context.Context, userID int64, since time.Time) ([]models.Session, error) {
+	query := `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at, revoked_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND created_at > $2
+		ORDER BY last_seen_at DESC`
+
+	rows, err := r.db.Query(ctx, query, userID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var s models.Session
+		err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// updates the last seen time and IP of a session
+func (r *Repository) TouchSession(ctx context.Context, id string, ip string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE sessions SET last_seen_at = $1, ip = $2 WHERE id = $3`,
+		time.Now(), ip, id)
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// revokes an active user session
+func (r *Repository) RevokeSession(ctx context.Context, userID int64, id string) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE sessions
+		SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`,
+		time.Now(), id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package router
+
+import (
+	"net/http"
+)
+
+// represents a group of routes sharing a path prefix and middlewares
+type Group struct {
+	router      *Router
+	prefix      string
+	middlewares []Middleware
+}
+
+// adds middlewares to the group chain
+func (g *Group) Use(middlewares ...Middleware) {
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+// creates a nested group with a longer prefix and an extended middleware chain
+func (g *Group) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{
+		router:      g.router,
+		prefix:      g.prefix + prefix,
+		middlewares: g.chain(middlewares),
+	}
+}
+
+// creates a group with the same prefix and an extended middleware chain
+func (g *Group) With(middlewares ...Middleware) *Group {
+	return g.Group("", middlewares...)
+}
+
+// registers a handler wrapped in the group middlewares
+func (g *Group) Handle(method, pattern string, handler http.Handler) {
+	middlewares := g.chain(nil)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	g.router.Handle(method, g.prefix+pattern, handler)
+}
+
+// registers a handler function wrapped in the group middlewares
+func (g *Group) HandleFunc(method, pattern string, handler http.HandlerFunc) {
+	g.Handle(method, pattern, handler)
+}
+
+// registers a GET handler
+func (g *Group) Get(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodGet, pattern, handler)
+}
+
+// registers a POST handler
+func (g *Group) Post(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPost, pattern, handler)
+}
+
+// registers a PUT handler
+func (g *Group) Put(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodPut, pattern, handler)
+}
+
+// registers a DELETE handler
+func (g *Group) Delete(pattern string, handler http.HandlerFunc) {
+	g.Handle(http.MethodDelete, pattern, handler)
+}
+
+// returns a copy of the group middlewares followed by extra ones
+func (g *Group) chain(extra []Middleware) []Middleware {
+	chain := make([]Middleware, 0, len(g.middlewares)+len(extra))
+	chain = append(chain, g.middlewares...)
+	return append(chain, extra...)
+}
@@ -0,0 +1,220 @@
+package router
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"gophermart/internal/utils"
+)
+
+// represents an HTTP middleware
+type Middleware func(http.Handler) http.Handler
+
+// represents a registered route
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  http.Handler
+}
+
+// represents a method-aware router with path parameters
+type Router struct {
+	routes      []*route
+	middlewares []Middleware
+	handler     http.Handler
+	once        sync.Once
+}
+
+// creates a new router
+func New() *Router {
+	return &Router{}
+}
+
+// adds middlewares that wrap every request, including unmatched ones;
+// must be called before the router starts serving
+func (rt *Router) Use(middlewares ...Middleware) {
+	rt.middlewares = append(rt.middlewares, middlewares...)
+}
+
+// registers a handler for a method and a pattern such as /api/orders/{number}
+func (rt *Router) Handle(method, pattern string, handler http.Handler) {
+	rt.routes = append(rt.routes, &route{
+		method:   method,
+		pattern:  pattern,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+// registers a handler function for a method and a pattern
+func (rt *Router) HandleFunc(method, pattern string, handler http.HandlerFunc) {
+	rt.Handle(method, pattern, handler)
+}
+
+// registers a GET handler
+func (rt *Router) Get(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, handler)
+}
+
+// registers a POST handler
+func (rt *Router) Post(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, handler)
+}
+
+// registers a PUT handler
+func (rt *Router) Put(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPut, pattern, handler)
+}
+
+// registers a DELETE handler
+func (rt *Router) Delete(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodDelete, pattern, handler)
+}
+
+// creates a route group sharing a path prefix and a middleware chain
+func (rt *Router) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{router: rt, prefix: strings.TrimSuffix(prefix, "/"), middlewares: middlewares}
+}
+
+// creates a route group without prefix sharing a middleware chain
+func (rt *Router) With(middlewares ...Middleware) *Group {
+	return rt.Group("", middlewares...)
+}
+
+// dispatches a request to the matching route
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.once.Do(func() {
+		var h http.Handler = http.HandlerFunc(rt.dispatch)
+		for i := len(rt.middlewares) - 1; i >= 0; i-- {
+			h = rt.middlewares[i](h)
+		}
+		rt.handler = h
+	})
+	rt.handler.ServeHTTP(w, r)
+}
+
+// finds the best matching route, answering 404 or 405 when there is none
+func (rt *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+
+	var best *route
+	allowed := map[string]bool{}
+	for _, rte := range rt.routes {
+		if !rte.matches(segments) {
+			continue
+		}
+		allowed[rte.method] = true
+		if rte.method == http.MethodGet {
+			allowed[http.MethodHead] = true
+		}
+		if rte.serves(r.Method) && (best == nil || rte.moreSpecificThan(best) || rte.method == r.Method && best.method != r.Method) {
+			best = rte
+		}
+	}
+
+	if best == nil {
+		if len(allowed) == 0 {
+			utils.SendError(w, r, http.StatusNotFound, utils.CodeRouteNotFound, "Not found")
+			return
+		}
+
+		methods := make([]string, 0, len(allowed))
+		for method := range allowed {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		utils.SendError(w, r, http.StatusMethodNotAllowed, utils.CodeMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if entry, ok := utils.GetAccessLogEntry(r.Context()); ok {
+		entry.Route = best.pattern
+	}
+
+	for i, segment := range best.segments {
+		if name, ok := paramName(segment); ok {
+			r.SetPathValue(name, segments[i])
+		}
+	}
+
+	// HEAD falls back to the GET handler, whose body is discarded
+	if r.Method == http.MethodHead && best.method == http.MethodGet {
+		w = headResponseWriter{w}
+	}
+
+	best.handler.ServeHTTP(w, r)
+}
+
+// reports whether a route handles a method, GET routes also answer HEAD
+func (rte *route) serves(method string) bool {
+	return rte.method == method || method == http.MethodHead && rte.method == http.MethodGet
+}
+
+// represents a response writer keeping the headers and dropping the body of a HEAD response
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+// discards the body
+func (w headResponseWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// flushes the headers of streamed responses
+func (w headResponseWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// returns the wrapped response writer
+func (w headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// checks if a route pattern matches path segments
+func (rte *route) matches(segments []string) bool {
+	if len(rte.segments) != len(segments) {
+		return false
+	}
+	for i, segment := range rte.segments {
+		if _, ok := paramName(segment); ok {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// reports whether a route has a literal segment where the other has a parameter first
+func (rte *route) moreSpecificThan(other *route) bool {
+	for i := range rte.segments {
+		_, param := paramName(rte.segments[i])
+		_, otherParam := paramName(other.segments[i])
+		if param != otherParam {
+			return !param
+		}
+	}
+	return false
+}
+
+// splits a path into segments
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// gets a parameter name from a {name} segment
+func paramName(segment string) (string, bool) {
+	if len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
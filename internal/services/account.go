@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+	"gophermart/internal/utils"
+)
+
+// represents a service handling data subject requests
+type AccountService struct {
+	repo      *repository.Repository
+	hasher    utils.PasswordHasher
+	twoFactor *TwoFactorService
+}
+
+// creates a new account service
+func NewAccountService(repo *repository.Repository, hasher utils.PasswordHasher, twoFactor *TwoFactorService) *AccountService {
+	return &AccountService{
+		repo:      repo,
+		hasher:    hasher,
+		twoFactor: twoFactor,
+	}
+}
+
+// collects all personal data stored about a user
+func (s *AccountService) Export(ctx context.Context, userID int64) (*models.UserExport, error) {
+	ctx, span := tracer.Start(ctx, "AccountService.Export")
+	defer span.End()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	balance, err := s.repo.GetUserBalance(ctx, int(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user balance: %w", err)
+	}
+
+	orders, err := s.repo.GetUserOrders(ctx, int(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user orders: %w", err)
+	}
+
+	withdrawals, err := s.repo.GetUserWithdrawals(ctx, int(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user withdrawals: %w", err)
+	}
+
+	history, err := s.repo.GetBalanceHistory(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance history: %w", err)
+	}
+
+	sessions, err := s.repo.GetActiveSessions(ctx, userID, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	export := &models.UserExport{
+		Profile: models.UserProfile{
+			Login:            user.Login,
+			Roles:            user.Roles,
+			TwoFactorEnabled: user.TOTPEnabled,
+			CreatedAt:        user.CreatedAt,
+		},
+		Balance:        *balance,
+		Orders:         orders,
+		Withdrawals:    withdrawals,
+		BalanceHistory: history,
+		Sessions:       sessions,
+		ExportedAt:     time.Now(),
+	}
+
+	// keep empty collections as arrays in the export
+	if export.Orders == nil {
+		export.Orders = []models.Order{}
+	}
+	if export.Withdrawals == nil {
+		export.Withdrawals = []models.Withdrawal{}
+	}
+	if export.BalanceHistory == nil {
+		export.BalanceHistory = []models.BalanceEntry{}
+	}
+	if export.Sessions == nil {
+		export.Sessions = []models.Session{}
+	}
+
+	return export, nil
+}
+
+// deletes a user account after re-checking its credentials
+func (s *AccountService) Delete(ctx context.Context, userID int64, password, totpCode string) error {
+	ctx, span := tracer.Start(ctx, "AccountService.Delete")
+	defer span.End()
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	valid, err := verifyPassword(ctx, s.hasher, password, user.PasswordHash)
+	if err != nil {
+		return fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !valid {
+		return ErrInvalidCredentials
+	}
+
+	if user.TOTPEnabled {
+		if err := s.twoFactor.VerifyTOTP(ctx, userID, totpCode); err != nil {
+			return err
+		}
+	}
+
+	// a concurrent deletion may have won the race
+	err = s.repo.AnonymizeUser(ctx, userID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gophermart/internal/models"
+	"gophermart/internal/utils"
+)
+
+func TestDeleteLeavesNoLoginInAuditLog(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	hasher, err := utils.NewArgon2idHasher(utils.DefaultArgon2Params())
+	if err != nil {
+		t.Fatalf("NewArgon2idHasher() error = %v", err)
+	}
+	users := NewUserService(repo, hasher, nil)
+	accounts := NewAccountService(repo, hasher, NewTwoFactorService(repo))
+
+	const login = "audit-private-login"
+	user, err := users.Register(ctx, login, "correct horse battery")
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if _, err := users.Authenticate(ctx, login, "wrong password"); err != ErrInvalidCredentials {
+		t.Fatalf("Authenticate() error = %v, want ErrInvalidCredentials", err)
+	}
+	if err := accounts.Delete(ctx, user.ID, "correct horse battery", ""); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	events, err := repo.GetAuditEvents(ctx, models.AuditFilter{UserID: user.ID, Limit: 100})
+	if err != nil {
+		t.Fatalf("GetAuditEvents() error = %v", err)
+	}
+	// the audit log survives database resets, so earlier users with the same ID may show up
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type == models.AuditUserRegistered {
+			events = events[i:]
+			break
+		}
+	}
+
+	deleted := false
+	for _, event := range events {
+		if strings.Contains(string(event.Before)+string(event.After), login) {
+			t.Errorf("%s event stores the login: %s", event.Type, event.After)
+		}
+		deleted = deleted || event.Type == models.AuditUserDeleted
+	}
+	if !deleted {
+		t.Errorf("no %s event among %d events", models.AuditUserDeleted, len(events))
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+)
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrInvalidOrderState = errors.New("invalid order status or accrual")
+	ErrInvalidAmount     = errors.New("invalid amount")
+)
+
+// represents an operator service for administrators
+type AdminService struct {
+	repo *repository.Repository
+}
+
+// creates a new admin service
+func NewAdminService(repo *repository.Repository) *AdminService {
+	return &AdminService{repo: repo}
+}
+
+// gets a user with balance by login
+func (s *AdminService) GetUser(ctx context.Context, login string) (*models.UserInfo, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.GetUser")
+	defer span.End()
+
+	user, err := s.repo.GetUserByLogin(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	balance, err := s.repo.GetUserBalance(ctx, int(user.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user balance: %w", err)
+	}
+
+	return &models.UserInfo{
+		ID:               user.ID,
+		Login:            user.Login,
+		Roles:            user.Roles,
+		TwoFactorEnabled: user.TOTPEnabled,
+		CreatedAt:        user.CreatedAt,
+		Balance:          *balance,
+	}, nil
+}
+
+// adds a signed amount to a user balance
+func (s *AdminService) AdjustBalance(ctx context.Context, adminID int64, login string, amount float32, reason string) (*models.UserBalance, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.AdjustBalance")
+	defer span.End()
+
+	if amount == 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	user, err := s.repo.GetUserByLogin(ctx, login)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	balance, err := s.repo.AdjustUserBalance(ctx, user.ID, adminID, amount, reason)
+	if errors.Is(err, repository.ErrInsufficientFunds) {
+		return nil, ErrInsufficientFunds
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to adjust balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// overrides an order status and accrual
+func (s *AdminService) OverrideOrderStatus(ctx context.Context, number, status string, accrual float32) (*models.Order, error) {
+	ctx, span := tracer.Start(ctx, "AdminService.OverrideOrderStatus")
+	defer span.End()
+
+	switch status {
+	case models.OrderStatusNew, models.OrderStatusProcessing, models.OrderStatusInvalid:
+		if accrual != 0 {
+			return nil, ErrInvalidOrderState
+		}
+	case models.OrderStatusProcessed:
+		if accrual < 0 {
+			return nil, ErrInvalidOrderState
+		}
+	default:
+		return nil, ErrInvalidOrderState
+	}
+
+	order, err := s.repo.OverrideOrderStatus(ctx, number, status, accrual)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return nil, ErrOrderNotFound
+	}
+	if errors.Is(err, repository.ErrInsufficientFunds) {
+		return nil, ErrInsufficientFunds
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to override order status: %w", err)
+	}
+
+	return order, nil
+}
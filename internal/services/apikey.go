@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+)
+
+const apiKeyPrefix = "gmk"
+
+var (
+	ErrInvalidAPIKey    = errors.New("invalid api key")
+	ErrAPIKeyNotFound   = errors.New("api key not found")
+	ErrInvalidScope     = errors.New("invalid api key scope")
+	ErrInvalidKeyName   = errors.New("invalid api key name")
+	ErrOnBehalfRequired = errors.New("on-behalf-of user is required")
+)
+
+// all scopes that can be granted to an API key
+var knownScopes = map[string]bool{
+	models.ScopeOrdersWrite:     true,
+	models.ScopeBalanceWithdraw: true,
+	models.ScopeUsersRead:       true,
+}
+
+// represents a partner API key service
+type APIKeyService struct {
+	repo *repository.Repository
+}
+
+// creates a new API key service
+func NewAPIKeyService(repo *repository.Repository) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// creates a new API key, the secret is only returned here
+func (s *APIKeyService) Create(ctx context.Context, adminID int64, name string, scopes []string) (*models.CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrInvalidKeyName
+	}
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !knownScopes[scope] {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	prefix, err := randomString(6)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	plain := apiKeyPrefix + "_" + prefix + "_" + secret
+
+	key := &models.APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		CreatedBy: &adminID,
+	}
+	if err := s.repo.CreateAPIKey(ctx, key, hashAPIKey(plain)); err != nil {
+		return nil, fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return &models.CreatedAPIKey{APIKey: *key, Key: plain}, nil
+}
+
+// gets a list of all API keys
+func (s *APIKeyService) List(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+// revokes an API key
+func (s *APIKeyService) Revoke(ctx context.Context, id int64) error {
+	err := s.repo.RevokeAPIKey(ctx, id)
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// checks an API key and resolves the user it acts on behalf of
+func (s *APIKeyService) Authenticate(ctx context.Context, plain, onBehalfOf, ip string) (*models.APIKey, *models.User, error) {
+	parts := strings.Split(plain, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	key, keyHash, err := s.repo.GetAPIKeyByPrefix(ctx, parts[1])
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(keyHash), []byte(hashAPIKey(plain))) != 1 || key.RevokedAt != nil {
+		return nil, nil, ErrInvalidAPIKey
+	}
+
+	if onBehalfOf == "" {
+		return nil, nil, ErrOnBehalfRequired
+	}
+	user, err := s.repo.GetUserByLogin(ctx, onBehalfOf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, nil, ErrUserNotFound
+	}
+
+	if err := s.repo.RecordAPIKeyUsage(ctx, key.ID, ip); err != nil {
+		slog.ErrorContext(ctx, "Failed to record usage of api key", "api_key_id", key.ID, "error", err)
+	}
+
+	return key, user, nil
+}
+
+// checks if an API key was granted a scope
+func HasScope(key *models.APIKey, scope string) bool {
+	for _, s := range key.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// hashes an API key for storage
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// generates a random URL-safe string without underscores
+func randomString(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate random string: %w", err)
+	}
+	return strings.ReplaceAll(base64.RawURLEncoding.EncodeToString(raw), "_", "-"), nil
+}
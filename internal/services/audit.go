@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+)
+
+const (
+	// audit events returned per page by default and at most
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+)
+
+// represents an audit log service
+type AuditService struct {
+	repo *repository.Repository
+}
+
+// creates a new audit log service
+func NewAuditService(repo *repository.Repository) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// gets a page of audit events matching a filter, oldest first
+func (s *AuditService) List(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	ctx, span := tracer.Start(ctx, "AuditService.List")
+	defer span.End()
+
+	validation := &ValidationError{}
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	if filter.Limit < 0 || filter.Limit > maxAuditPageSize {
+		validation.Add("limit", "out_of_range", fmt.Sprintf("must be between 1 and %d", maxAuditPageSize))
+	}
+	if filter.AfterID < 0 {
+		validation.Add("after_id", "out_of_range", "must not be negative")
+	}
+	if err := validation.OrNil(); err != nil {
+		return nil, err
+	}
+
+	events, err := s.repo.GetAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get audit events: %w", err)
+	}
+	return events, nil
+}
+
+// checks that no audit event was changed, removed or reordered
+func (s *AuditService) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	ctx, span := tracer.Start(ctx, "AuditService.Verify")
+	defer span.End()
+
+	return s.repo.VerifyAuditChain(ctx)
+}
@@ -15,26 +15,57 @@ var (
 
 // represents a balance service
 type BalanceService struct {
-	repo *repository.Repository
+	repo      *repository.Repository
+	twoFactor *TwoFactorService
+	// withdrawals above this sum require a fresh TOTP code from enrolled users
+	twoFactorThreshold float32
 }
 
 // creates a new balance service
-func NewBalanceService(repo *repository.Repository) *BalanceService {
-	return &BalanceService{repo: repo}
+func NewBalanceService(repo *repository.Repository, twoFactor *TwoFactorService, twoFactorThreshold float32) *BalanceService {
+	return &BalanceService{
+		repo:               repo,
+		twoFactor:          twoFactor,
+		twoFactorThreshold: twoFactorThreshold,
+	}
 }
 
 // gets a user balance
 func (s *BalanceService) GetBalance(ctx context.Context, userID int) (*models.UserBalance, error) {
+	ctx, span := tracer.Start(ctx, "BalanceService.GetBalance")
+	defer span.End()
+
 	return s.repo.GetUserBalance(ctx, userID)
 }
 
 // creates a withdrawal
-func (s *BalanceService) CreateWithdrawal(ctx context.Context, userID int, orderNumber string, amount float32) error {
+func (s *BalanceService) CreateWithdrawal(ctx context.Context, userID int, orderNumber string, amount float32, totpCode string) error {
+	ctx, span := tracer.Start(ctx, "BalanceService.CreateWithdrawal")
+	defer span.End()
+
+	// only a positive sum can be withdrawn, NaN fails this check too
+	if !(amount > 0) {
+		return ErrInvalidAmount
+	}
+
 	// check if order number is valid
 	if !isValidLuhn(orderNumber) {
 		return ErrInvalidOrderNumber
 	}
 
+	// large withdrawals require a fresh TOTP code from users with 2FA enabled
+	if amount > s.twoFactorThreshold {
+		enabled, err := s.twoFactor.IsEnabled(ctx, int64(userID))
+		if err != nil {
+			return fmt.Errorf("failed to check two-factor status: %w", err)
+		}
+		if enabled {
+			if err := s.twoFactor.VerifyTOTP(ctx, int64(userID), totpCode); err != nil {
+				return err
+			}
+		}
+	}
+
 	// check if user has enough funds
 	balance, err := s.repo.GetUserBalance(ctx, userID)
 	if err != nil {
@@ -50,5 +81,8 @@ func (s *BalanceService) CreateWithdrawal(ctx context.Context, userID int, order
 
 // gets a user withdrawal history
 func (s *BalanceService) GetWithdrawals(ctx context.Context, userID int) ([]models.Withdrawal, error) {
+	ctx, span := tracer.Start(ctx, "BalanceService.GetWithdrawals")
+	defer span.End()
+
 	return s.repo.GetUserWithdrawals(ctx, userID)
 }
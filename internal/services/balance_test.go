@@ -0,0 +1,20 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCreateWithdrawalRejectsNonPositiveSum(t *testing.T) {
+	// the sum is checked before the repository is touched, so none is needed
+	s := NewBalanceService(nil, nil, 500)
+
+	for _, amount := range []float32{0, -1, -0.01, float32(math.NaN())} {
+		err := s.CreateWithdrawal(context.Background(), 1, "79927398713", amount, "")
+		if !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("CreateWithdrawal(sum=%v) = %v, want ErrInvalidAmount", amount, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"strings"
+
+	"gophermart/internal/utils"
+)
+
+// represents invalid input, listing every offending field
+type ValidationError struct {
+	Fields []utils.FieldError
+}
+
+// creates a validation error for a single field
+func NewValidationError(field, code, message string) *ValidationError {
+	return &ValidationError{Fields: []utils.FieldError{{Field: field, Code: code, Message: message}}}
+}
+
+// adds a field failure
+func (e *ValidationError) Add(field, code, message string) {
+	e.Fields = append(e.Fields, utils.FieldError{Field: field, Code: code, Message: message})
+}
+
+// returns the error or nil if no field failed
+func (e *ValidationError) OrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Field+": "+f.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"sync"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+)
+
+// maximum number of events read from the log at once
+const orderEventBatchSize = 100
+
+// represents a service streaming order events to their owners
+type OrderEventService struct {
+	repo *repository.Repository
+
+	mu          sync.Mutex
+	subscribers map[int64]map[chan struct{}]struct{}
+}
+
+// creates a new order event service
+func NewOrderEventService(repo *repository.Repository) *OrderEventService {
+	return &OrderEventService{
+		repo:        repo,
+		subscribers: make(map[int64]map[chan struct{}]struct{}),
+	}
+}
+
+// subscribes to wake-ups on new events of a user; the returned function unsubscribes
+func (s *OrderEventService) Subscribe(userID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	s.mu.Lock()
+	if s.subscribers[userID] == nil {
+		s.subscribers[userID] = make(map[chan struct{}]struct{})
+	}
+	s.subscribers[userID][ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[userID], ch)
+		if len(s.subscribers[userID]) == 0 {
+			delete(s.subscribers, userID)
+		}
+	}
+}
+
+// wakes up the subscribers of the users the events belong to
+func (s *OrderEventService) Publish(events []models.OrderEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		for ch := range s.subscribers[event.UserID] {
+			// a pending wake-up already covers this event
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// gets user events recorded after an event ID, oldest first
+func (s *OrderEventService) Since(ctx context.Context, userID, afterID int64) ([]models.OrderEvent, error) {
+	return s.repo.GetOrderEvents(ctx, userID, afterID, orderEventBatchSize)
+}
+
+// gets the ID of the latest user event, used as the starting point of a new stream
+func (s *OrderEventService) LatestID(ctx context.Context, userID int64) (int64, error) {
+	return s.repo.GetLatestOrderEventID(ctx, userID)
+}
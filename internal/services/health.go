@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+)
+
+const (
+	// time limit of a single readiness check
+	healthCheckTimeout = 2 * time.Second
+	// number of missed sweeps after which the accrual worker is considered stuck
+	workerStaleSweeps = 3
+)
+
+// represents a readiness check; degraded checks do not make the service unready
+type healthCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// represents a health service
+type HealthService struct {
+	checks       []healthCheck
+	shuttingDown atomic.Bool
+}
+
+// creates a new health service
+func NewHealthService(repo *repository.Repository, orderService *OrderService) *HealthService {
+	return &HealthService{
+		checks: []healthCheck{
+			{name: "database", critical: true, check: repo.Ping},
+			{name: "migrations", critical: true, check: repo.CheckMigrations},
+			{name: "accrual_worker", critical: true, check: func(context.Context) error {
+				age := time.Since(orderService.WorkerHeartbeat())
+				if age > workerStaleSweeps*orderService.SweepInterval() {
+					return fmt.Errorf("last active %s ago", age.Round(time.Second))
+				}
+				return nil
+			}},
+			{name: "accrual_system", check: orderService.PingAccrual},
+		},
+	}
+}
+
+// marks the service as shutting down, readiness fails from now on
+func (s *HealthService) SetShuttingDown() {
+	s.shuttingDown.Store(true)
+}
+
+// reports that the process is alive
+func (s *HealthService) Liveness() models.HealthReport {
+	return models.HealthReport{Status: models.HealthStatusOK}
+}
+
+// runs every readiness check concurrently
+func (s *HealthService) Readiness(ctx context.Context) models.HealthReport {
+	report := models.HealthReport{
+		Status: models.HealthStatusOK,
+		Checks: make(map[string]models.HealthCheck, len(s.checks)+1),
+	}
+	if s.shuttingDown.Load() {
+		report.Status = models.HealthStatusFailed
+		report.Checks["shutdown"] = models.HealthCheck{Status: models.HealthStatusFailed, Error: "shutting down"}
+		return report
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range s.checks {
+		wg.Add(1)
+		go func(c healthCheck) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.check(checkCtx)
+			result := models.HealthCheck{
+				Status:    models.HealthStatusOK,
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if err != nil {
+				result.Error = err.Error()
+				result.Status = models.HealthStatusDegraded
+				if c.critical {
+					result.Status = models.HealthStatusFailed
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if result.Status == models.HealthStatusFailed {
+				report.Status = models.HealthStatusFailed
+			} else if result.Status == models.HealthStatusDegraded && report.Status == models.HealthStatusOK {
+				report.Status = models.HealthStatusDegraded
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return report
+}
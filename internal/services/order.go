@@ -5,10 +5,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"gophermart/internal/metrics"
 	"gophermart/internal/models"
 	"gophermart/internal/repository"
 )
@@ -19,6 +29,9 @@ var (
 	ErrOrderExistsForOtherUser = errors.New("order already exists for another user")
 )
 
+// maximum number of orders in a batch upload
+const MaxOrderBatchSize = 1000
+
 // represents an accrual response
 type accrualResponse struct {
 	Order   string  `json:"order"`
@@ -26,20 +39,62 @@ type accrualResponse struct {
 	Accrual float32 `json:"accrual,omitempty"`
 }
 
+const (
+	// bounds of the delay before reconnecting a dropped listen connection
+	minListenRetryDelay = time.Second
+	maxListenRetryDelay = 30 * time.Second
+
+	// time a claim outlives the accrual request, covering the status update after it
+	claimLeaseMargin = 30 * time.Second
+)
+
 // represents an order service
 type OrderService struct {
-	repo                *repository.Repository
-	accrualSystemURL    string
-	accrualCheckTimeout time.Duration
+	repo             *repository.Repository
+	eventService     *OrderEventService
+	accrualSystemURL string
+	accrualClient    *http.Client
+	// how long an order is claimed for while it is checked
+	claimLease time.Duration
+	// nanoseconds between rechecks of orders still in processing when no notifications arrive
+	sweepInterval atomic.Int64
+	// wakes the worker up to apply a new sweep interval
+	sweepIntervalChanged chan struct{}
+	// accrual checks made per order not yet final, owned by the worker goroutine
+	attempts map[string]int
+	// unix nanoseconds of the last accrual worker activity
+	heartbeat atomic.Int64
+	// accrual worker state reported by diagnostics
+	listening     atomic.Bool
+	reconnects    atomic.Int64
+	pendingOrders atomic.Int64
+	lastSweep     atomic.Int64
+}
+
+// represents the accrual worker state
+type AccrualWorkerState struct {
+	LastActive    time.Time `json:"last_active"`
+	LastSweep     time.Time `json:"last_sweep"`
+	SweepInterval string    `json:"sweep_interval"`
+	PendingOrders int64     `json:"pending_orders"`
+	Listening     bool      `json:"listening"`
+	Reconnects    int64     `json:"reconnects"`
 }
 
 // creates a new order service
-func NewOrderService(repo *repository.Repository, accrualSystemURL string) *OrderService {
+func NewOrderService(repo *repository.Repository, eventService *OrderEventService, accrualSystemURL string, sweepInterval, accrualTimeout time.Duration) *OrderService {
 	service := &OrderService{
-		repo:                repo,
-		accrualSystemURL:    accrualSystemURL,
-		accrualCheckTimeout: 1 * time.Second,
+		repo:             repo,
+		eventService:     eventService,
+		accrualSystemURL: accrualSystemURL,
+		accrualClient:    &http.Client{Timeout: accrualTimeout},
+		claimLease:       accrualTimeout + claimLeaseMargin,
+		attempts:         make(map[string]int),
+
+		sweepIntervalChanged: make(chan struct{}, 1),
 	}
+	service.sweepInterval.Store(int64(sweepInterval))
+	service.heartbeat.Store(time.Now().UnixNano())
 
 	// start goroutine for checking order statuses
 	go service.startAccrualCheck()
@@ -47,81 +102,224 @@ func NewOrderService(repo *repository.Repository, accrualSystemURL string) *Orde
 	return service
 }
 
-// starts a periodic check of order statuses
+// checks new orders as soon as they are uploaded and periodically sweeps orders still in processing
 func (s *OrderService) startAccrualCheck() {
-	ticker := time.NewTicker(s.accrualCheckTimeout)
+	newOrders := make(chan string, 100)
+	resync := make(chan struct{}, 1)
+	go s.listenNewOrders(newOrders, resync)
+
+	ticker := time.NewTicker(s.SweepInterval())
 	defer ticker.Stop()
 
-	for range ticker.C {
-		ctx := context.Background()
-		orders, err := s.repo.GetProcessingOrders(ctx)
-		if err != nil {
-			fmt.Printf("Failed to get processing orders: %v\n", err)
-			continue
+	ctx := context.Background()
+	s.sweepOrders(ctx)
+	for {
+		select {
+		case number := <-newOrders:
+			s.processOrder(ctx, number)
+		case <-resync:
+			s.sweepOrders(ctx)
+		case <-ticker.C:
+			s.sweepOrders(ctx)
+		case <-s.sweepIntervalChanged:
+			// sweep right away so that the heartbeat is fresh for the new interval
+			ticker.Reset(s.SweepInterval())
+			s.sweepOrders(ctx)
 		}
+	}
+}
 
-		for _, order := range orders {
-			status, accrual, err := s.checkAccrualStatus(ctx, order.Number)
-			if err != nil {
-				fmt.Printf("Failed to check accrual status for order %s: %v\n", order.Number, err)
-				continue
+// listens for uploaded orders on a dedicated connection, reconnecting when it drops;
+// a resync is requested after every reconnect to catch orders missed meanwhile
+func (s *OrderService) listenNewOrders(newOrders chan<- string, resync chan<- struct{}) {
+	ctx := context.Background()
+	delay := minListenRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		started := time.Now()
+		err := s.repo.ListenNewOrders(ctx, func() {
+			s.listening.Store(true)
+			select {
+			case resync <- struct{}{}:
+			default:
 			}
+		}, func(number string) {
+			newOrders <- number
+		})
+		s.listening.Store(false)
+		s.reconnects.Add(1)
+
+		// a connection that stayed up for a while starts backing off anew
+		if time.Since(started) > maxListenRetryDelay {
+			delay = minListenRetryDelay
+			attempt = 1
+		}
 
-			fmt.Printf("Order %s status: %s, accrual: %f\n", order.Number, status, accrual)
+		slog.WarnContext(ctx, "Listening for new orders stopped", "error", err, "attempt", attempt, "retry_in", delay)
+		time.Sleep(delay)
+		delay = min(delay*2, maxListenRetryDelay)
+	}
+}
 
-			userID, err := s.repo.CheckOrderExists(ctx, order.Number)
-			if err != nil {
-				fmt.Printf("Failed to get user ID for order %s: %v\n", order.Number, err)
-				continue
-			}
+// checks all orders still in processing
+func (s *OrderService) sweepOrders(ctx context.Context) {
+	s.heartbeat.Store(time.Now().UnixNano())
+	orders, err := s.repo.GetProcessingOrders(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get processing orders", "error", err)
+		return
+	}
 
-			err = s.repo.UpdateOrderStatus(ctx, order.Number, status, accrual)
-			if err != nil {
-				fmt.Printf("Failed to update order status for order %s: %v\n", order.Number, err)
-				continue
-			}
+	// orders finalized elsewhere, e.g. by another replica or an admin, are no longer counted
+	pending := make(map[string]bool, len(orders))
+	for _, order := range orders {
+		pending[order.Number] = true
+	}
+	for number := range s.attempts {
+		if !pending[number] {
+			delete(s.attempts, number)
+		}
+	}
 
-			if status == "PROCESSED" && accrual > 0 {
-				balance, err := s.repo.GetUserBalance(ctx, userID)
-				if err != nil {
-					fmt.Printf("Failed to get user balance for user %d: %v\n", userID, err)
-					continue
-				}
+	s.lastSweep.Store(time.Now().UnixNano())
+	s.pendingOrders.Store(int64(len(orders)))
+	metrics.PendingOrders.Set(float64(len(orders)))
+	slog.DebugContext(ctx, "Sweeping orders in processing", "count", len(orders))
+	for _, order := range orders {
+		s.processOrder(ctx, order.Number)
+	}
+}
 
-				fmt.Printf("Current balance for user %d: %f\n", userID, balance.Current)
+// checks an order in the accrual system and stores its new status
+func (s *OrderService) processOrder(ctx context.Context, number string) {
+	ctx, span := tracer.Start(ctx, "OrderService.processOrder", trace.WithAttributes(attribute.String("order.number", number)))
+	defer span.End()
+	s.heartbeat.Store(time.Now().UnixNano())
 
-				err = s.repo.UpdateUserBalance(ctx, userID, accrual)
-				if err != nil {
-					fmt.Printf("Failed to update user balance for user %d: %v\n", userID, err)
-					continue
-				}
+	// notifications reach every replica and race the sweep, the claim lets a single worker check the order
+	userID, ok, err := s.repo.ClaimOrder(ctx, number, s.claimLease)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to claim order", "order", number, "error", err)
+		return
+	}
+	if !ok {
+		slog.DebugContext(ctx, "Order is final or checked by another worker", "order", number)
+		return
+	}
+	defer func() {
+		if err := s.repo.ReleaseOrder(ctx, number); err != nil {
+			slog.ErrorContext(ctx, "Failed to release order", "order", number, "error", err)
+		}
+	}()
 
-				balance, err = s.repo.GetUserBalance(ctx, userID)
-				if err != nil {
-					fmt.Printf("Failed to get updated balance for user %d: %v\n", userID, err)
-					continue
-				}
+	s.attempts[number]++
+	attempt := s.attempts[number]
 
-				fmt.Printf("Updated balance for user %d: %f\n", userID, balance.Current)
-			}
-		}
+	status, accrual, err := s.checkAccrualStatus(ctx, number)
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to check accrual status", "order", number, "attempt", attempt, "error", err)
+		return
+	}
+
+	slog.DebugContext(ctx, "Accrual status checked", "order", number, "attempt", attempt, "status", status, "accrual", accrual)
+	if status == "PROCESSED" || status == "INVALID" {
+		delete(s.attempts, number)
+	}
+
+	// a processed accrual is credited in the same transaction as the status
+	events, err := s.repo.UpdateOrderStatus(ctx, number, status, accrual)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update order status", "order", number, "status", status, "error", err)
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+	s.eventService.Publish(events)
+	slog.InfoContext(ctx, "Order status changed", "order", number, "attempt", attempt, "user_id", userID, "status", status, "accrual", accrual)
+}
+
+// returns when the accrual worker was last active
+func (s *OrderService) WorkerHeartbeat() time.Time {
+	return time.Unix(0, s.heartbeat.Load())
+}
+
+// returns a snapshot of the accrual worker state
+func (s *OrderService) WorkerState() AccrualWorkerState {
+	state := AccrualWorkerState{
+		LastActive:    s.WorkerHeartbeat(),
+		SweepInterval: s.SweepInterval().String(),
+		PendingOrders: s.pendingOrders.Load(),
+		Listening:     s.listening.Load(),
+		Reconnects:    s.reconnects.Load(),
+	}
+	if lastSweep := s.lastSweep.Load(); lastSweep != 0 {
+		state.LastSweep = time.Unix(0, lastSweep)
 	}
+	return state
+}
+
+// returns the interval between sweeps, the worker is active at least this often
+func (s *OrderService) SweepInterval() time.Duration {
+	return time.Duration(s.sweepInterval.Load())
+}
+
+// changes the interval between sweeps of the running worker
+func (s *OrderService) SetSweepInterval(interval time.Duration) {
+	if time.Duration(s.sweepInterval.Swap(int64(interval))) == interval {
+		return
+	}
+	select {
+	case s.sweepIntervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+// checks that the accrual system answers HTTP requests
+func (s *OrderService) PingAccrual(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.accrualSystemURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.accrualClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
 }
 
 // checkAccrualStatus checks the status of an order in the accrual system
 func (s *OrderService) checkAccrualStatus(ctx context.Context, orderNumber string) (string, float32, error) {
 	url := fmt.Sprintf("%s/api/orders/%s", s.accrualSystemURL, orderNumber)
+	ctx, span := tracer.Start(ctx, "GET /api/orders/{number}",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(http.MethodGet), semconv.URLFull(url)),
+	)
+	defer span.End()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", 0, err
 	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := s.accrualClient.Do(req)
+	metrics.AccrualRequestDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
+		metrics.AccrualRequests.WithLabelValues("error").Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return "", 0, err
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	metrics.AccrualRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
 
 	if resp.StatusCode == http.StatusNoContent {
 		return "NEW", 0, nil
@@ -145,6 +343,9 @@ func (s *OrderService) checkAccrualStatus(ctx context.Context, orderNumber strin
 
 // creates a new order
 func (s *OrderService) CreateOrder(ctx context.Context, userID int, orderNumber string) error {
+	ctx, span := tracer.Start(ctx, "OrderService.CreateOrder")
+	defer span.End()
+
 	// check if order number is valid
 	if !isValidLuhn(orderNumber) {
 		return ErrInvalidOrderNumber
@@ -164,8 +365,70 @@ func (s *OrderService) CreateOrder(ctx context.Context, userID int, orderNumber
 	return nil
 }
 
+// creates orders in bulk, reporting the outcome of every number in input order
+func (s *OrderService) CreateOrderBatch(ctx context.Context, userID int, numbers []string) ([]models.OrderBatchResult, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.CreateOrderBatch")
+	defer span.End()
+
+	if len(numbers) == 0 {
+		return nil, NewValidationError("orders", "empty", "must contain at least one order number")
+	}
+	if len(numbers) > MaxOrderBatchSize {
+		return nil, NewValidationError("orders", "too_many", fmt.Sprintf("must contain at most %d order numbers", MaxOrderBatchSize))
+	}
+
+	results := make([]models.OrderBatchResult, len(numbers))
+	valid := make([]string, 0, len(numbers))
+	seen := make(map[string]bool, len(numbers))
+	for i, number := range numbers {
+		results[i].Number = number
+		if number == "" || !isValidLuhn(number) {
+			results[i].Status = models.OrderBatchInvalid
+			continue
+		}
+		if !seen[number] {
+			seen[number] = true
+			valid = append(valid, number)
+		}
+	}
+
+	owners := map[string]int{}
+	if len(valid) > 0 {
+		var err error
+		owners, err = s.repo.CreateOrders(ctx, userID, valid)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create orders: %w", err)
+		}
+	}
+
+	// repeated numbers within the batch count as already uploaded after the first one
+	accepted := make(map[string]bool, len(valid))
+	for i := range results {
+		if results[i].Status != "" {
+			continue
+		}
+
+		number := results[i].Number
+		ownerID, existed := owners[number]
+		switch {
+		case existed && ownerID != userID:
+			results[i].Status = models.OrderBatchOwnedByOther
+		case existed || accepted[number]:
+			results[i].Status = models.OrderBatchAlreadyUploaded
+		default:
+			results[i].Status = models.OrderBatchAccepted
+			accepted[number] = true
+		}
+	}
+
+	return results, nil
+}
+
 // gets a list of user orders
 func (s *OrderService) GetUserOrders(ctx context.Context, userID int) ([]models.Order, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetUserOrders")
+	defer span.End()
+
 	return s.repo.GetUserOrders(ctx, userID)
 }
 
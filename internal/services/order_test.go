@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// connects to the database in TEST_DATABASE_URI, skipping the test without one;
+// every table but the audit log is reset first, so the database must be a disposable one
+func newTestRepository(t *testing.T) *repository.Repository {
+	t.Helper()
+
+	uri := os.Getenv("TEST_DATABASE_URI")
+	if uri == "" {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+
+	// the migration is read relative to the module root
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	if err := os.Chdir("../.."); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	reset, err := os.ReadFile("migrations/reset.sql")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	conn, err := pgx.Connect(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(context.Background(), string(reset)); err != nil {
+		t.Fatalf("failed to reset database: %v", err)
+	}
+
+	repo, err := repository.NewRepository(uri, 4, 0)
+	if err != nil {
+		t.Fatalf("NewRepository() error = %v", err)
+	}
+	t.Cleanup(repo.Close)
+	return repo
+}
+
+func TestProcessOrderCreditsAccrualOnce(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	// a fractional accrual does not survive the trip to DECIMAL and back exactly
+	const number = "79927398713"
+	const accrual float32 = 729.98
+	accrualSystem := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(accrualResponse{Order: number, Status: "PROCESSED", Accrual: accrual})
+	}))
+	defer accrualSystem.Close()
+
+	user := &models.User{Login: "accrual-once", PasswordHash: "hash"}
+	if err := repo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := repo.CreateOrder(ctx, int(user.ID), number); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	// built by hand so the background worker does not poll the order too
+	s := &OrderService{
+		repo:             repo,
+		eventService:     NewOrderEventService(repo),
+		accrualSystemURL: accrualSystem.URL,
+		accrualClient:    accrualSystem.Client(),
+		attempts:         make(map[string]int),
+	}
+	// a second check, e.g. a sweep racing the notification, must not credit again
+	s.processOrder(ctx, number)
+	s.processOrder(ctx, number)
+
+	balance, err := repo.GetUserBalance(ctx, int(user.ID))
+	if err != nil {
+		t.Fatalf("GetUserBalance() error = %v", err)
+	}
+	if balance.Current != accrual {
+		t.Errorf("balance = %v, want the accrual of %v", balance.Current, accrual)
+	}
+
+	// a worker that claimed the order before it became final must not credit again either
+	events, err := repo.UpdateOrderStatus(ctx, number, models.OrderStatusProcessed, accrual)
+	if err != nil {
+		t.Fatalf("UpdateOrderStatus() error = %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("UpdateOrderStatus() of a processed order = %d events, want none", len(events))
+	}
+}
+
+func TestClaimOrderExcludesOtherWorkers(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	const number = "12345678903"
+	user := &models.User{Login: "claim-once", PasswordHash: "hash"}
+	if err := repo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if err := repo.CreateOrder(ctx, int(user.ID), number); err != nil {
+		t.Fatalf("CreateOrder() error = %v", err)
+	}
+
+	if _, ok, err := repo.ClaimOrder(ctx, number, time.Minute); err != nil || !ok {
+		t.Fatalf("first ClaimOrder() = %v, %v, want a claim", ok, err)
+	}
+	if _, ok, err := repo.ClaimOrder(ctx, number, time.Minute); err != nil || ok {
+		t.Fatalf("second ClaimOrder() = %v, %v, want the order taken", ok, err)
+	}
+	if err := repo.ReleaseOrder(ctx, number); err != nil {
+		t.Fatalf("ReleaseOrder() error = %v", err)
+	}
+	if _, ok, err := repo.ClaimOrder(ctx, number, time.Minute); err != nil || !ok {
+		t.Fatalf("ClaimOrder() after release = %v, %v, want a claim", ok, err)
+	}
+}
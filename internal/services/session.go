@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+)
+
+const (
+	sessionIDLength = 16
+	// how stale last seen may get before it is refreshed
+	sessionTouchInterval = time.Minute
+	maxUserAgentLength   = 512
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session is terminated")
+)
+
+// represents a login session service
+type SessionService struct {
+	repo *repository.Repository
+	// lifetime of a session and of the access tokens issued for it
+	ttl time.Duration
+}
+
+// creates a new session service
+func NewSessionService(repo *repository.Repository, ttl time.Duration) *SessionService {
+	return &SessionService{repo: repo, ttl: ttl}
+}
+
+// returns the lifetime of sessions and their access tokens
+func (s *SessionService) TTL() time.Duration {
+	return s.ttl
+}
+
+// starts a new session for a user
+func (s *SessionService) Create(ctx context.Context, userID int64, userAgent, ip string) (*models.Session, error) {
+	ctx, span := tracer.Start(ctx, "SessionService.Create")
+	defer span.End()
+
+	raw := make([]byte, sessionIDLength)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate session id: %w", err)
+	}
+
+	userAgent = truncateUTF8(userAgent, maxUserAgentLength)
+
+	session := &models.Session{
+		ID:        hex.EncodeToString(raw),
+		UserID:    userID,
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// gets a list of active user sessions, marking the current one
+func (s *SessionService) List(ctx context.Context, userID int64, currentID string) ([]models.Session, error) {
+	sessions, err := s.repo.GetActiveSessions(ctx, userID, time.Now().Add(-s.ttl))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	return sessions, nil
+}
+
+// terminates a user session
+func (s *SessionService) Revoke(ctx context.Context, userID int64, sessionID string) error {
+	err := s.repo.RevokeSession(ctx, userID, sessionID)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+// checks that a session belongs to the user and is still active, refreshing its last seen time
+func (s *SessionService) Validate(ctx context.Context, userID int64, sessionID, ip string) error {
+	ctx, span := tracer.Start(ctx, "SessionService.Validate")
+	defer span.End()
+
+	// sessions removed along with a deleted account count as terminated
+	session, err := s.repo.GetSession(ctx, sessionID)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return err
+	}
+
+	if session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval || session.IP != ip {
+		if err := s.repo.TouchSession(ctx, sessionID, ip); err != nil {
+			slog.ErrorContext(ctx, "Failed to touch session", "session_id", sessionID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// cuts a string to at most n bytes without splitting a rune, replacing invalid UTF-8
+func truncateUTF8(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		n    int
+		want string
+	}{
+		{"short", "curl/8.0", 512, "curl/8.0"},
+		{"ascii", "abcdef", 4, "abcd"},
+		{"rune boundary", "ab" + "яя", 5, "abя"},
+		{"inside rune", "a" + "€€", 5, "a€"},
+		{"invalid input", "a\xffb", 512, "a�b"},
+	}
+	for _, tt := range tests {
+		got := truncateUTF8(tt.in, tt.n)
+		if got != tt.want {
+			t.Errorf("%s: truncateUTF8(%q, %d) = %q, want %q", tt.name, tt.in, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) || len(got) > tt.n {
+			t.Errorf("%s: result %q is invalid or longer than %d bytes", tt.name, got, tt.n)
+		}
+	}
+
+	long := strings.Repeat("ж", maxUserAgentLength)
+	if got := truncateUTF8(long, maxUserAgentLength); len(got) != maxUserAgentLength || !utf8.ValidString(got) {
+		t.Errorf("long user agent truncated to %d bytes, valid = %v", len(got), utf8.ValidString(got))
+	}
+}
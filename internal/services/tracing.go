@@ -0,0 +1,24 @@
+package services
+
+import (
+	"context"
+
+	"gophermart/internal/tracing"
+	"gophermart/internal/utils"
+)
+
+var tracer = tracing.Tracer("gophermart/internal/services")
+
+// hashes a password in its own span, it is often the slowest part of a request
+func hashPassword(ctx context.Context, hasher utils.PasswordHasher, password string) (string, error) {
+	_, span := tracer.Start(ctx, "PasswordHasher.Hash")
+	defer span.End()
+	return hasher.Hash(password)
+}
+
+// verifies a password in its own span
+func verifyPassword(ctx context.Context, hasher utils.PasswordHasher, password, encodedHash string) (bool, error) {
+	_, span := tracer.Start(ctx, "PasswordHasher.Verify")
+	defer span.End()
+	return hasher.Verify(password, encodedHash)
+}
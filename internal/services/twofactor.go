@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gophermart/internal/models"
+	"gophermart/internal/repository"
+	"gophermart/internal/utils"
+)
+
+const (
+	totpIssuer        = "Gophermart"
+	recoveryCodeCount = 10
+	// wrong codes in a row after which two-factor checks are refused for totpLockout
+	maxTOTPFailures = 5
+	totpLockout     = 15 * time.Minute
+)
+
+var (
+	ErrTwoFactorNotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorRequired       = errors.New("two-factor code required")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrTwoFactorLocked         = errors.New("too many invalid two-factor codes")
+)
+
+// represents a two-factor authentication service
+type TwoFactorService struct {
+	repo *repository.Repository
+}
+
+// creates a new two-factor authentication service
+func NewTwoFactorService(repo *repository.Repository) *TwoFactorService {
+	return &TwoFactorService{repo: repo}
+}
+
+// starts a TOTP enrollment by generating a new secret
+func (s *TwoFactorService) Setup(ctx context.Context, userID int64) (*models.TOTPEnrollment, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{
+		Secret: secret,
+		URI:    utils.TOTPURI(totpIssuer, user.Login, secret),
+	}, nil
+}
+
+// confirms a TOTP enrollment and returns freshly generated recovery codes
+func (s *TwoFactorService) Confirm(ctx context.Context, userID int64, code string) ([]string, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotEnrolled
+	}
+
+	if err := s.limitFailures(ctx, user, func() error { return s.useTOTP(ctx, user, code) }); err != nil {
+		return nil, err
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, utils.HashRecoveryCode(c))
+	}
+
+	if err := s.repo.EnableTOTP(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// disables two-factor authentication after checking a TOTP or recovery code
+func (s *TwoFactorService) Disable(ctx context.Context, userID int64, code, recoveryCode string) error {
+	if err := s.Verify(ctx, userID, code, recoveryCode); err != nil {
+		return err
+	}
+	return s.repo.DisableTOTP(ctx, userID)
+}
+
+// checks a TOTP code or, if no code is given, a single-use recovery code
+func (s *TwoFactorService) Verify(ctx context.Context, userID int64, code, recoveryCode string) error {
+	ctx, span := tracer.Start(ctx, "TwoFactorService.Verify")
+	defer span.End()
+
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return ErrTwoFactorNotEnrolled
+	}
+	if code == "" && recoveryCode == "" {
+		return ErrTwoFactorRequired
+	}
+
+	return s.limitFailures(ctx, user, func() error {
+		if code != "" {
+			return s.useTOTP(ctx, user, code)
+		}
+
+		used, err := s.repo.UseRecoveryCode(ctx, userID, utils.HashRecoveryCode(recoveryCode))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	})
+}
+
+// runs a code check unless the user is locked out, counting wrong codes towards a lockout
+func (s *TwoFactorService) limitFailures(ctx context.Context, user *models.User, check func() error) error {
+	if user.TOTPLockedUntil != nil && time.Now().Before(*user.TOTPLockedUntil) {
+		return ErrTwoFactorLocked
+	}
+
+	err := check()
+	if errors.Is(err, ErrInvalidTwoFactorCode) {
+		if err := s.repo.RecordTOTPFailure(ctx, user.ID, maxTOTPFailures, time.Now().Add(totpLockout)); err != nil {
+			return err
+		}
+		return ErrInvalidTwoFactorCode
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.repo.ResetTOTPFailures(ctx, user.ID)
+}
+
+// checks a fresh TOTP code, recovery codes are not accepted
+func (s *TwoFactorService) VerifyTOTP(ctx context.Context, userID int64, code string) error {
+	if code == "" {
+		return ErrTwoFactorRequired
+	}
+	return s.Verify(ctx, userID, code, "")
+}
+
+// reports whether a user has two-factor authentication enabled
+func (s *TwoFactorService) IsEnabled(ctx context.Context, userID int64) (bool, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.TOTPEnabled, nil
+}
+
+// validates a TOTP code and records its time step so it cannot be replayed
+func (s *TwoFactorService) useTOTP(ctx context.Context, user *models.User, code string) error {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.repo.UseTOTPStep(ctx, user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+
+	return nil
+}
+
+// gets a user or returns ErrUserNotFound
+func (s *TwoFactorService) getUser(ctx context.Context, userID int64) (*models.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
 	"unicode/utf8"
 
 	"gophermart/internal/models"
@@ -12,33 +15,57 @@ import (
 )
 
 var (
-	ErrUserNotFound    = errors.New("user not found")
-	ErrUserExists      = errors.New("user already exists")
-	ErrInvalidPassword = errors.New("invalid password")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrInvalidCredentials = errors.New("invalid login or password")
+)
+
+const (
+	minLoginLength    = 3
+	minPasswordLength = 6
 )
 
 // represents a user service
 type UserService struct {
-	repo *repository.Repository
+	repo   *repository.Repository
+	hasher utils.PasswordHasher
+	// logins that are granted the admin role on registration
+	adminLogins map[string]bool
+
+	// hash verified for unknown logins, so that they take as long as wrong passwords
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
 // creates a new user service
-func NewUserService(repo *repository.Repository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo *repository.Repository, hasher utils.PasswordHasher, adminLogins []string) *UserService {
+	admins := make(map[string]bool, len(adminLogins))
+	for _, login := range adminLogins {
+		admins[login] = true
+	}
+	return &UserService{repo: repo, hasher: hasher, adminLogins: admins}
 }
 
 // registers a new user
 func (s *UserService) Register(ctx context.Context, login, password string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Register")
+	defer span.End()
 
-	if login == "" || utf8.RuneCountInString(login) < 3 {
-		return nil, fmt.Errorf("invalid login")
+	validation := &ValidationError{}
+	if utf8.RuneCountInString(login) < minLoginLength {
+		validation.Add("login", "too_short", fmt.Sprintf("must be at least %d characters", minLoginLength))
 	}
-
-	if password == "" || utf8.RuneCountInString(password) < 6 {
-		return nil, ErrInvalidPassword
+	if strings.HasPrefix(login, models.DeletedLoginPrefix) {
+		validation.Add("login", "reserved", fmt.Sprintf("must not start with %q", models.DeletedLoginPrefix))
+	}
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		validation.Add("password", "too_short", fmt.Sprintf("must be at least %d characters", minPasswordLength))
+	}
+	if err := validation.OrNil(); err != nil {
+		return nil, err
 	}
 
-	hashedPassword, err := utils.HashPassword(password)
+	hashedPassword, err := hashPassword(ctx, s.hasher, password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -46,6 +73,10 @@ func (s *UserService) Register(ctx context.Context, login, password string) (*mo
 	user := &models.User{
 		Login:        login,
 		PasswordHash: hashedPassword,
+		Roles:        []string{models.RoleUser},
+	}
+	if s.adminLogins[login] {
+		user.Roles = append(user.Roles, models.RoleAdmin)
 	}
 
 	err = s.repo.CreateUser(ctx, user)
@@ -61,9 +92,18 @@ func (s *UserService) Register(ctx context.Context, login, password string) (*mo
 
 // authenticates a user
 func (s *UserService) Authenticate(ctx context.Context, login, password string) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.Authenticate")
+	defer span.End()
 
-	if login == "" || password == "" {
-		return nil, fmt.Errorf("login and password are required")
+	validation := &ValidationError{}
+	if login == "" {
+		validation.Add("login", "required", "is required")
+	}
+	if password == "" {
+		validation.Add("password", "required", "is required")
+	}
+	if err := validation.OrNil(); err != nil {
+		return nil, err
 	}
 
 	user, err := s.repo.GetUserByLogin(ctx, login)
@@ -71,12 +111,78 @@ func (s *UserService) Authenticate(ctx context.Context, login, password string)
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
-		return nil, ErrUserNotFound
+		s.verifyDummyPassword(ctx, password)
+		s.recordLoginFailure(ctx, 0, "unknown_login")
+		return nil, ErrInvalidCredentials
+	}
+
+	valid, err := verifyPassword(ctx, s.hasher, password, user.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify password: %w", err)
+	}
+	if !valid {
+		s.recordLoginFailure(ctx, user.ID, "wrong_password")
+		return nil, ErrInvalidCredentials
+	}
+
+	// upgrade legacy or outdated hashes while the plain password is at hand
+	if s.hasher.NeedsRehash(user.PasswordHash) {
+		s.rehashPassword(ctx, user, password)
+	}
+
+	return user, nil
+}
+
+// verifies a password against a hash of the current parameters, spending the time a known login would
+func (s *UserService) verifyDummyPassword(ctx context.Context, password string) {
+	s.dummyHashOnce.Do(func() {
+		hash, err := s.hasher.Hash("dummy password")
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to hash dummy password", "error", err)
+			return
+		}
+		s.dummyHash = hash
+	})
+	if s.dummyHash != "" {
+		verifyPassword(ctx, s.hasher, password, s.dummyHash)
 	}
+}
 
-	if !utils.CheckPasswordHash(password, user.PasswordHash) {
-		return nil, ErrInvalidPassword
+// records a failed login in the audit log; the attempted login is not stored,
+// audit events outlive account deletion
+func (s *UserService) recordLoginFailure(ctx context.Context, userID int64, reason string) {
+	err := s.repo.RecordAuditEvent(ctx, models.AuditLoginFailed, userID, map[string]string{
+		"reason": reason,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record login failure", "error", err)
 	}
+}
 
+// gets a user by ID
+func (s *UserService) GetByID(ctx context.Context, userID int64) (*models.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
 	return user, nil
 }
+
+// replaces a user password hash with one produced by the current hasher
+func (s *UserService) rehashPassword(ctx context.Context, user *models.User, password string) {
+	hashedPassword, err := hashPassword(ctx, s.hasher, password)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to rehash password", "user_id", user.ID, "error", err)
+		return
+	}
+
+	if err := s.repo.UpdateUserPasswordHash(ctx, user.ID, hashedPassword); err != nil {
+		slog.ErrorContext(ctx, "Failed to store rehashed password", "user_id", user.ID, "error", err)
+		return
+	}
+
+	user.PasswordHash = hashedPassword
+}
@@ -0,0 +1,21 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegisterRejectsDeletedLoginPrefix(t *testing.T) {
+	// the login is checked before the repository is touched, so none is needed
+	s := NewUserService(nil, nil, nil)
+
+	_, err := s.Register(context.Background(), "deleted-42", "secret password")
+	var validation *ValidationError
+	if !errors.As(err, &validation) {
+		t.Fatalf("Register() error = %v, want a validation error", err)
+	}
+	if len(validation.Fields) != 1 || validation.Fields[0].Code != "reserved" {
+		t.Errorf("Register() fields = %+v, want a reserved login", validation.Fields)
+	}
+}
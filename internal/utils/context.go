@@ -7,11 +7,26 @@ import (
 type contextKey string
 
 const (
-	UserIDKey contextKey = "user_id"
+	UserIDKey    contextKey = "user_id"
+	RolesKey     contextKey = "roles"
+	APIKeyKey    contextKey = "api_key_id"
+	SessionIDKey contextKey = "session_id"
+	RequestIDKey contextKey = "request_id"
+	AccessLogKey contextKey = "access_log"
+	ClientIPKey  contextKey = "client_ip"
 )
 
+// represents request details filled in by inner handlers for the access log
+type AccessLogEntry struct {
+	Route  string
+	UserID int64
+}
+
 // adds a user ID to the context
 func WithUserID(ctx context.Context, userID int64) context.Context {
+	if entry, ok := GetAccessLogEntry(ctx); ok {
+		entry.UserID = userID
+	}
 	return context.WithValue(ctx, UserIDKey, userID)
 }
 
@@ -20,3 +35,81 @@ func GetUserID(ctx context.Context) (int64, bool) {
 	userID, ok := ctx.Value(UserIDKey).(int64)
 	return userID, ok
 }
+
+// adds user roles to the context
+func WithRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, RolesKey, roles)
+}
+
+// gets user roles from the context
+func GetRoles(ctx context.Context) []string {
+	roles, _ := ctx.Value(RolesKey).([]string)
+	return roles
+}
+
+// checks if the context user has any of the roles
+func HasAnyRole(ctx context.Context, roles ...string) bool {
+	for _, have := range GetRoles(ctx) {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// adds the ID of the API key used to authenticate to the context
+func WithAPIKeyID(ctx context.Context, keyID int64) context.Context {
+	return context.WithValue(ctx, APIKeyKey, keyID)
+}
+
+// gets the ID of the API key used to authenticate from the context
+func GetAPIKeyID(ctx context.Context) (int64, bool) {
+	keyID, ok := ctx.Value(APIKeyKey).(int64)
+	return keyID, ok
+}
+
+// adds a session ID to the context
+func WithSessionID(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, SessionIDKey, sessionID)
+}
+
+// gets a session ID from the context
+func GetSessionID(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(SessionIDKey).(string)
+	return sessionID, ok
+}
+
+// adds a request ID to the context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, RequestIDKey, requestID)
+}
+
+// gets a request ID from the context
+func GetRequestID(ctx context.Context) (string, bool) {
+	requestID, ok := ctx.Value(RequestIDKey).(string)
+	return requestID, ok
+}
+
+// adds an access log entry to the context
+func WithAccessLogEntry(ctx context.Context, entry *AccessLogEntry) context.Context {
+	return context.WithValue(ctx, AccessLogKey, entry)
+}
+
+// gets the access log entry from the context
+func GetAccessLogEntry(ctx context.Context) (*AccessLogEntry, bool) {
+	entry, ok := ctx.Value(AccessLogKey).(*AccessLogEntry)
+	return entry, ok
+}
+
+// adds the client IP address to the context
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ClientIPKey, ip)
+}
+
+// gets the client IP address from the context
+func GetClientIP(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(ClientIPKey).(string)
+	return ip, ok
+}
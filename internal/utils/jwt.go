@@ -2,38 +2,134 @@ package utils
 
 import (
 	"errors"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	// purpose of a token issued after the password step of a two-factor login
+	TokenPurposeMFA = "mfa"
+
+	mfaTokenTTL = 5 * time.Minute
+)
+
+// signing secret with the previous one accepted until the grace period ends
+type SigningKey struct {
+	mu        sync.RWMutex
+	current   []byte
+	previous  []byte
+	rotatedAt time.Time
+	grace     time.Duration
+}
+
+// creates a signing key accepting the previous secret for grace after a rotation, never if grace is zero
+func NewSigningKey(secret string, grace time.Duration) *SigningKey {
+	return &SigningKey{current: []byte(secret), grace: grace}
+}
+
+// replaces the secret, reporting whether it changed
+func (k *SigningKey) Rotate(secret string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if string(k.current) == secret {
+		return false
+	}
+	k.previous = k.current
+	k.current = []byte(secret)
+	k.rotatedAt = time.Now()
+	return true
+}
+
+// returns the secret new tokens are signed with
+func (k *SigningKey) signing() []byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// returns the secrets tokens are verified with, the current one first
+func (k *SigningKey) verifying() [][]byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := [][]byte{k.current}
+	if k.previous != nil && time.Since(k.rotatedAt) < k.grace {
+		keys = append(keys, k.previous)
+	}
+	return keys
+}
+
+// keeps the secret out of logs
+func (k *SigningKey) LogValue() slog.Value {
+	return slog.StringValue("REDACTED")
+}
+
 // represents a JWT token claims
 type Claims struct {
-	UserID int64 `json:"user_id"`
+	UserID    int64    `json:"user_id"`
+	SessionID string   `json:"sid,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Purpose   string   `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// generates a JWT token for a user
-func GenerateToken(userID int64, secret string) (string, error) {
+// generates a JWT token for a user valid for ttl
+func GenerateToken(userID int64, sessionID string, roles []string, key *SigningKey, ttl time.Duration) (string, error) {
+	claims := &Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Roles:     roles,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(key.signing())
+}
+
+// generates a short-lived token that only allows completing a two-factor login
+func GenerateMFAToken(userID int64, key *SigningKey) (string, error) {
 	claims := &Claims{
-		UserID: userID,
+		UserID:  userID,
+		Purpose: TokenPurposeMFA,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+	return token.SignedString(key.signing())
 }
 
 // parses and validates a JWT token
-func ParseToken(tokenString string, secret string) (*Claims, error) {
+func ParseToken(tokenString string, key *SigningKey) (*Claims, error) {
+	var firstErr error
+	for _, secret := range key.verifying() {
+		claims, err := parseToken(tokenString, secret)
+		if err == nil {
+			return claims, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// parses and validates a JWT token signed with secret
+func parseToken(tokenString string, secret []byte) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("invalid signing method")
 		}
-		return []byte(secret), nil
+		return secret, nil
 	})
 
 	if err != nil {
@@ -1,26 +1,74 @@
 package utils
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
-)
+	"strings"
 
-var (
-	InfoLogger  *log.Logger
-	ErrorLogger *log.Logger
+	"go.opentelemetry.io/otel/trace"
 )
 
-func init() {
-	InfoLogger = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+// level of the default logger, changeable while running
+var logLevel = new(slog.LevelVar)
+
+// configures the default logger; level is debug, info, warn or error and format is text or json
+func SetupLogger(level, format string) error {
+	if err := SetLogLevel(level); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// changes the level of the default logger
+func SetLogLevel(level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	logLevel.Set(lvl)
+	return nil
+}
+
+// represents a log handler adding request-scoped fields from the context
+type contextHandler struct {
+	slog.Handler
+}
+
+// adds the request ID, user ID and trace IDs to a record
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID, ok := GetRequestID(ctx); ok {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if userID, ok := GetUserID(ctx); ok {
+		record.AddAttrs(slog.Int64("user_id", userID))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
 }
 
-// logs an info message
-func LogInfo(format string, v ...interface{}) {
-	InfoLogger.Printf(format, v...)
+// returns a handler with extra attributes
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
 }
 
-// logs an error message
-func LogError(format string, v ...interface{}) {
-	ErrorLogger.Printf(format, v...)
+// returns a handler with an attribute group
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
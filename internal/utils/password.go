@@ -1,17 +1,203 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"gophermart/internal/metrics"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// hashes a password
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes), err
+var (
+	ErrInvalidHash         = errors.New("invalid password hash format")
+	ErrIncompatibleVersion = errors.New("incompatible argon2 version")
+)
+
+// represents a password hasher
+type PasswordHasher interface {
+	// hashes a password into a self-describing encoded string
+	Hash(password string) (string, error)
+	// checks if a password matches an encoded hash
+	Verify(password, encodedHash string) (bool, error)
+	// reports whether an encoded hash should be replaced with a fresh one
+	NeedsRehash(encodedHash string) bool
+}
+
+// represents argon2id parameters
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// returns the recommended argon2id parameters
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// returns argon2id parameters with the recommended salt and key lengths, rejecting values that do not fit
+func NewArgon2Params(memory, iterations, parallelism uint) (Argon2Params, error) {
+	if memory > math.MaxUint32 || iterations > math.MaxUint32 || parallelism > math.MaxUint8 {
+		return Argon2Params{}, fmt.Errorf("argon2 parameters out of range: m=%d, t=%d, p=%d", memory, iterations, parallelism)
+	}
+	params := DefaultArgon2Params()
+	params.Memory = uint32(memory)
+	params.Iterations = uint32(iterations)
+	params.Parallelism = uint8(parallelism)
+	return params, params.validate()
 }
 
-// checks if a password matches a hash
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+// checks that the parameters can be used for hashing, argon2 panics on some invalid ones
+func (p Argon2Params) validate() error {
+	switch {
+	case p.Iterations < 1:
+		return errors.New("argon2 iterations must be at least 1")
+	case p.Parallelism < 1:
+		return errors.New("argon2 parallelism must be at least 1")
+	case p.Memory < 8*uint32(p.Parallelism):
+		return errors.New("argon2 memory must be at least 8 KiB per degree of parallelism")
+	case p.SaltLength < 8:
+		return errors.New("argon2 salt length must be at least 8 bytes")
+	case p.KeyLength < 16:
+		return errors.New("argon2 key length must be at least 16 bytes")
+	}
+	return nil
+}
+
+// represents an argon2id password hasher that also verifies legacy bcrypt hashes
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+// creates a new argon2id password hasher, rejecting invalid parameters
+func NewArgon2idHasher(params Argon2Params) (*Argon2idHasher, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	return &Argon2idHasher{params: params}, nil
+}
+
+// hashes a password in PHC string format
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	defer observeHashDuration("hash", "argon2id", time.Now())
+
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// checks if a password matches an argon2id or bcrypt hash
+func (h *Argon2idHasher) Verify(password, encodedHash string) (bool, error) {
+	if isBcryptHash(encodedHash) {
+		defer observeHashDuration("verify", "bcrypt", time.Now())
+		err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	params, salt, key, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return false, err
+	}
+
+	defer observeHashDuration("verify", "argon2id", time.Now())
+	otherKey := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	return subtle.ConstantTimeCompare(key, otherKey) == 1, nil
+}
+
+// reports whether a hash uses another algorithm or outdated parameters
+func (h *Argon2idHasher) NeedsRehash(encodedHash string) bool {
+	params, salt, _, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		params.KeyLength != h.params.KeyLength ||
+		uint32(len(salt)) != h.params.SaltLength
+}
+
+// records the time a password hashing operation took since start
+func observeHashDuration(operation, algorithm string, start time.Time) {
+	metrics.PasswordHashDuration.WithLabelValues(operation, algorithm).Observe(time.Since(start).Seconds())
+}
+
+// checks if a hash was produced by bcrypt
+func isBcryptHash(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+// decodes an argon2id hash in PHC string format
+func decodeArgon2idHash(encodedHash string) (Argon2Params, []byte, []byte, error) {
+	var params Argon2Params
+
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return params, nil, nil, ErrIncompatibleVersion
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	// zero iterations or parallelism would make argon2 panic
+	if params.Iterations < 1 || params.Parallelism < 1 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
 }
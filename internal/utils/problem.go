@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// prefix of problem type URIs, followed by the stable error code
+const problemTypePrefix = "urn:problem:gophermart:"
+
+// stable machine-readable error codes
+const (
+	CodeInternal            = "internal.error"
+	CodeInvalidBody         = "request.invalid_body"
+	CodeBodyTooLarge        = "request.body_too_large"
+	CodeUnsupportedMedia    = "request.unsupported_media_type"
+	CodeUnsupportedEncoding = "request.unsupported_encoding"
+	CodeInvalidParameter    = "request.invalid_parameter"
+	CodeValidationFailed    = "request.validation_failed"
+	CodeRouteNotFound       = "route.not_found"
+	CodeMethodNotAllowed    = "route.method_not_allowed"
+	CodeRateLimited         = "request.rate_limited"
+
+	CodeAuthRequired       = "auth.required"
+	CodeAuthInvalidHeader  = "auth.invalid_header"
+	CodeAuthInvalidToken   = "auth.invalid_token"
+	CodeAuthSessionEnded   = "auth.session_terminated"
+	CodeAuthForbidden      = "auth.forbidden"
+	CodeInvalidCredentials = "auth.invalid_credentials"
+
+	CodeUserNotFound   = "user.not_found"
+	CodeUserLoginTaken = "user.login_taken"
+
+	CodeOrderLuhnInvalid   = "order.luhn_invalid"
+	CodeOrderOwnedByOther  = "order.owned_by_other_user"
+	CodeOrderNotFound      = "order.not_found"
+	CodeOrderStatusInvalid = "order.status_invalid"
+
+	CodeBalanceInsufficient  = "balance.insufficient"
+	CodeBalanceAmountInvalid = "balance.amount_invalid"
+
+	CodeTwoFactorRequired       = "two_factor.required"
+	CodeTwoFactorCodeInvalid    = "two_factor.code_invalid"
+	CodeTwoFactorNotEnrolled    = "two_factor.not_enrolled"
+	CodeTwoFactorAlreadyEnabled = "two_factor.already_enabled"
+	CodeTwoFactorLocked         = "two_factor.locked"
+
+	CodeAPIKeyInvalid          = "api_key.invalid"
+	CodeAPIKeyNotFound         = "api_key.not_found"
+	CodeAPIKeyScopeInvalid     = "api_key.scope_invalid"
+	CodeAPIKeyScopeMissing     = "api_key.scope_missing"
+	CodeAPIKeyNameInvalid      = "api_key.name_invalid"
+	CodeAPIKeyOnBehalfRequired = "api_key.on_behalf_required"
+
+	CodeSessionNotFound = "session.not_found"
+)
+
+// represents a validation failure of a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// represents an RFC 7807 problem details response
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// creates a problem with a stable code and a human-readable detail
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   problemTypePrefix + code,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// sends a problem filling in the request instance and ID
+func SendProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if r != nil {
+		p.Instance = r.URL.Path
+		if requestID, ok := GetRequestID(r.Context()); ok {
+			p.RequestID = requestID
+		}
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
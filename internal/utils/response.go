@@ -19,12 +19,9 @@ func SendJSON(w http.ResponseWriter, status int, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// sends a JSON response with an error
-func SendError(w http.ResponseWriter, status int, message string) {
-	SendJSON(w, status, Response{
-		Status:  "error",
-		Message: message,
-	})
+// sends an application/problem+json response with a stable error code
+func SendError(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	SendProblem(w, r, NewProblem(status, code, detail))
 }
 
 // sends a JSON response with a success result
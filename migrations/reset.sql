@@ -0,0 +1,13 @@
+-- drops every table but the audit log, for development and test databases; never run at startup.
+-- audit_events is kept: it is append-only and its hash chain must survive a reset;
+-- user IDs start over, so earlier audit events may name IDs that new users get
+DROP TABLE IF EXISTS order_events;
+DROP TABLE IF EXISTS rate_limits;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS balance_adjustments;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS withdrawals;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS user_balances;
+DROP TABLE IF EXISTS users;